@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lonepeon/golib/logger"
+)
+
+var ErrShutdownTimeout = errors.New("component didn't stop before the shutdown deadline")
+
+// Component is a long running part of the application such as job.Server.
+// ListenAndServe blocks until Shutdown is called.
+type Component interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+type componentFunc struct {
+	serve    func() error
+	shutdown func(context.Context) error
+}
+
+// Func turns a pair of functions into a Component, for instance to bind a
+// web.Server to its address:
+//
+//	app.Func(func() error { return server.ListenAndServe(":8080") }, server.Shutdown)
+func Func(serve func() error, shutdown func(context.Context) error) Component {
+	return componentFunc{serve: serve, shutdown: shutdown}
+}
+
+func (c componentFunc) ListenAndServe() error {
+	return c.serve()
+}
+
+func (c componentFunc) Shutdown(ctx context.Context) error {
+	return c.shutdown(ctx)
+}
+
+type component struct {
+	Component
+
+	name string
+	done chan struct{}
+	err  error
+}
+
+// Runner starts components together and stops all of them as soon as one
+// stops, a signal is received or the context given to Run is cancelled.
+type Runner struct {
+	log        *logger.Logger
+	closer     logger.Closer
+	components []*component
+
+	ShutdownTimeout time.Duration
+	Signals         []os.Signal
+}
+
+// NewRunner creates a runner stopping on SIGINT and SIGTERM. closer is called
+// once every component stopped so the last logs are flushed, it can be nil.
+func NewRunner(log *logger.Logger, closer logger.Closer) *Runner {
+	return &Runner{
+		log:             log,
+		closer:          closer,
+		ShutdownTimeout: 30 * time.Second,
+		Signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+	}
+}
+
+// Add registers c under name. Components are started in the order they are
+// added and shut down in reverse order.
+func (r *Runner) Add(name string, c Component) {
+	r.components = append(r.components, &component{Component: c, name: name})
+}
+
+// Run blocks until the application stops. It returns the first failure: a
+// component stopping with an error, a shutdown error or a component not
+// stopping before the ShutdownTimeout deadline.
+func (r *Runner) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	if len(r.Signals) > 0 {
+		signal.Notify(signals, r.Signals...)
+		defer signal.Stop(signals)
+	}
+
+	stopped := make(chan *component, len(r.components))
+	for _, c := range r.components {
+		c.done = make(chan struct{})
+		go func(c *component) {
+			err := c.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				c.err = fmt.Errorf("%s failed: %w", c.name, err)
+			}
+			close(c.done)
+			stopped <- c
+		}(c)
+	}
+
+	r.log.Infof("application started with %d components", len(r.components))
+
+	var first *component
+	select {
+	case sig := <-signals:
+		r.log.Infof("stopping application: received %v", sig)
+	case <-ctx.Done():
+		r.log.Infof("stopping application: %v", ctx.Err())
+	case first = <-stopped:
+		r.log.Infof("stopping application: %s stopped", first.name)
+	}
+
+	err := r.shutdown(first)
+	r.log.Info("application stopped")
+
+	if r.closer != nil {
+		if closeErr := r.closer(); closeErr != nil && err == nil {
+			err = fmt.Errorf("can't flush logger: %v", closeErr)
+		}
+	}
+
+	return err
+}
+
+// shutdown stops the components still running. first is the component which
+// stopped the application, if any: its failure caused the others, so it's the
+// one reported.
+func (r *Runner) shutdown(first *component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(r.components) - 1; i >= 0; i-- {
+		c := r.components[i]
+		log := r.log.WithFields(logger.String("component", c.name))
+
+		if err := shutdownComponent(ctx, c); err != nil {
+			log.Errorf("can't shutdown component: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Info("component stopped")
+	}
+
+	if first != nil && first.err != nil {
+		return first.err
+	}
+
+	// failures happen before shutdown errors, so they are reported first. The
+	// error of a component still running can't be read.
+	for _, c := range r.components {
+		select {
+		case <-c.done:
+			if c.err != nil {
+				return c.err
+			}
+		default:
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// shutdownComponent asks c to stop unless it's already stopped and waits for
+// its ListenAndServe to return.
+func shutdownComponent(ctx context.Context, c *component) error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- c.Shutdown(ctx) }()
+
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			return fmt.Errorf("can't shutdown %s: %w", c.name, err)
+		}
+	case <-c.done:
+		return nil
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", c.name, ErrShutdownTimeout)
+	}
+}
@@ -0,0 +1,174 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/app"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+)
+
+type fakeComponent struct {
+	name        string
+	started     chan struct{}
+	stop        chan error
+	stopOnce    sync.Once
+	stubborn    bool
+	shutdownErr error
+	shutdowns   *shutdownRecorder
+}
+
+type shutdownRecorder struct {
+	l     sync.Mutex
+	names []string
+}
+
+func (r *shutdownRecorder) record(name string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.names = append(r.names, name)
+}
+
+func newFakeComponent(name string, shutdowns *shutdownRecorder) *fakeComponent {
+	return &fakeComponent{name: name, started: make(chan struct{}), stop: make(chan error, 1), shutdowns: shutdowns}
+}
+
+func (c *fakeComponent) ListenAndServe() error {
+	close(c.started)
+	return <-c.stop
+}
+
+func (c *fakeComponent) Shutdown(ctx context.Context) error {
+	c.shutdowns.record(c.name)
+	if !c.stubborn {
+		err := c.shutdownErr
+		if err == nil {
+			err = http.ErrServerClosed
+		}
+		c.fail(err)
+	}
+
+	return nil
+}
+
+func (c *fakeComponent) fail(err error) {
+	c.stopOnce.Do(func() { c.stop <- err })
+}
+
+func TestRunnerStopsOnContextCancellation(t *testing.T) {
+	shutdowns := &shutdownRecorder{}
+	web := newFakeComponent("web", shutdowns)
+	jobs := newFakeComponent("jobs", shutdowns)
+
+	closed := false
+	runner := newRunner(t, func() error { closed = true; return nil })
+	runner.Add("jobs", jobs)
+	runner.Add("web", web)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := run(ctx, runner)
+	<-web.started
+	<-jobs.started
+	cancel()
+
+	testutils.AssertNoError(t, <-done, "unexpected run error")
+	testutils.AssertEqualStrings(t, []string{"web", "jobs"}, shutdowns.names, "expected components to be stopped in reverse order")
+	testutils.AssertEqualBool(t, true, closed, "expected logger to be flushed")
+}
+
+func TestRunnerStopsOnSignal(t *testing.T) {
+	shutdowns := &shutdownRecorder{}
+	web := newFakeComponent("web", shutdowns)
+
+	runner := newRunner(t, nil)
+	runner.Signals = []os.Signal{syscall.SIGUSR1}
+	runner.Add("web", web)
+
+	done := run(context.Background(), runner)
+	<-web.started
+	testutils.RequireNoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1), "can't send signal")
+
+	testutils.AssertNoError(t, <-done, "unexpected run error")
+	testutils.AssertEqualStrings(t, []string{"web"}, shutdowns.names, "expected component to be stopped")
+}
+
+func TestRunnerReportsFirstFailure(t *testing.T) {
+	shutdowns := &shutdownRecorder{}
+	web := newFakeComponent("web", shutdowns)
+	jobs := newFakeComponent("jobs", shutdowns)
+
+	runner := newRunner(t, nil)
+	runner.Add("jobs", jobs)
+	runner.Add("web", web)
+
+	done := run(context.Background(), runner)
+	<-web.started
+	<-jobs.started
+	jobs.fail(errors.New("database is locked"))
+
+	err := <-done
+	testutils.AssertEqualString(t, "jobs failed: database is locked", err.Error(), "unexpected run error")
+	testutils.AssertEqualStrings(t, []string{"web"}, shutdowns.names, "expected only running components to be stopped")
+}
+
+func TestRunnerReportsFailureStoppingTheApplication(t *testing.T) {
+	shutdowns := &shutdownRecorder{}
+	web := newFakeComponent("web", shutdowns)
+	jobs := newFakeComponent("jobs", shutdowns)
+	jobs.shutdownErr = errors.New("interrupted job")
+
+	runner := newRunner(t, nil)
+	runner.Add("jobs", jobs)
+	runner.Add("web", web)
+
+	done := run(context.Background(), runner)
+	<-web.started
+	<-jobs.started
+	web.fail(errors.New("address already in use"))
+
+	err := <-done
+	testutils.AssertEqualString(t, "web failed: address already in use", err.Error(), "unexpected run error")
+	testutils.AssertEqualStrings(t, []string{"jobs"}, shutdowns.names, "expected only running components to be stopped")
+}
+
+func TestRunnerShutdownDeadline(t *testing.T) {
+	shutdowns := &shutdownRecorder{}
+	web := newFakeComponent("web", shutdowns)
+	web.stubborn = true
+	t.Cleanup(func() { web.fail(nil) })
+
+	runner := newRunner(t, nil)
+	runner.ShutdownTimeout = 10 * time.Millisecond
+	runner.Add("web", web)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := run(ctx, runner)
+	<-web.started
+	cancel()
+
+	testutils.AssertErrorIs(t, app.ErrShutdownTimeout, <-done, "unexpected run error")
+}
+
+func newRunner(t *testing.T, closer func() error) *app.Runner {
+	log, _, logCloser := loggertest.NewFake(t)
+	t.Cleanup(logCloser)
+
+	runner := app.NewRunner(log, closer)
+	runner.Signals = nil
+
+	return runner
+}
+
+func run(ctx context.Context, runner *app.Runner) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+
+	return done
+}
@@ -0,0 +1,266 @@
+package job
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lonepeon/golib/sqlutil/sqliteutil"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobLocked   = errors.New("job is currently locked by a worker")
+)
+
+type Details struct {
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	State           State           `json:"state"`
+	Params          json.RawMessage `json:"params"`
+	At              time.Time       `json:"at"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	SingletonKey    string          `json:"singleton_key,omitempty"`
+	TraceID         string          `json:"trace_id,omitempty"`
+	LockedUntil     *time.Time      `json:"locked_until,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	LastError       string          `json:"last_error,omitempty"`
+	Progress        int             `json:"progress"`
+	ProgressMessage string          `json:"progress_message,omitempty"`
+}
+
+type ListFilter struct {
+	Name  string
+	State State
+	Limit int
+}
+
+type PurgeFilter struct {
+	Name           string
+	State          State
+	FinishedBefore time.Time
+}
+
+type Admin struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewAdmin(db *sql.DB) *Admin {
+	return &Admin{db: db, now: time.Now}
+}
+
+func (a *Admin) List(filter ListFilter) ([]Details, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Name != "" {
+		conditions = append(conditions, "name = ?")
+		args = append(args, filter.Name)
+	}
+
+	if filter.State != "" {
+		conditions = append(conditions, "state = ?")
+		args = append(args, filter.State)
+	}
+
+	query := `SELECT id, name, params, at, attempts, max_attempts, state, locked_until, finished_at, last_error, progress, progress_message, singleton_key, trace_id FROM jobs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY at ASC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't list jobs: %w: %v", ErrGeneric, err)
+	}
+	defer rows.Close()
+
+	var jobs []Details
+	for rows.Next() {
+		details, err := scanDetails(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, details)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't iterate over jobs: %w: %v", ErrGeneric, err)
+	}
+
+	return jobs, nil
+}
+
+func (a *Admin) Lookup(id string) (Details, error) {
+	row := a.db.QueryRow(`
+		SELECT id, name, params, at, attempts, max_attempts, state, locked_until, finished_at, last_error, progress, progress_message, singleton_key, trace_id
+		FROM jobs
+		WHERE id = ?`, id)
+
+	details, err := scanDetails(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Details{}, fmt.Errorf("can't lookup job (id=%s): %w", id, ErrJobNotFound)
+		}
+		return Details{}, err
+	}
+
+	return details, nil
+}
+
+// Retry reschedules a failed or cancelled job for immediate execution with a fresh set of attempts.
+func (a *Admin) Retry(id string) error {
+	now := a.now()
+
+	return a.transition(id, StateScheduled, func(from State) (sql.Result, error) {
+		return a.db.Exec(`
+			UPDATE jobs
+			SET state = ?, attempts = 1, at = ?, locked_until = NULL, finished_at = NULL, last_error = NULL, progress = 0, progress_message = NULL
+			WHERE id = ? AND state = ?`, StateScheduled, now, id, from)
+	})
+}
+
+// Cancel prevents a job which has not been picked up by a worker yet from being executed.
+func (a *Admin) Cancel(id string) error {
+	now := a.now()
+
+	return a.transition(id, StateCancelled, func(from State) (sql.Result, error) {
+		return a.db.Exec(`
+			UPDATE jobs
+			SET state = ?, locked_until = NULL, finished_at = ?
+			WHERE id = ? AND state = ?`, StateCancelled, now, id, from)
+	})
+}
+
+// Purge deletes finished jobs and returns how many were removed.
+func (a *Admin) Purge(filter PurgeFilter) (int64, error) {
+	if filter.State != "" && !filter.State.IsFinal() {
+		return 0, fmt.Errorf("can't purge %s jobs: only finished jobs can be purged: %w", filter.State, ErrGeneric)
+	}
+
+	query := `DELETE FROM jobs WHERE state IN (?, ?, ?)`
+	args := []interface{}{StateSucceeded, StateFailed, StateCancelled}
+
+	if filter.State != "" {
+		query += " AND state = ?"
+		args = append(args, filter.State)
+	}
+
+	if filter.Name != "" {
+		query += " AND name = ?"
+		args = append(args, filter.Name)
+	}
+
+	if !filter.FinishedBefore.IsZero() {
+		query += " AND finished_at <= ?"
+		args = append(args, filter.FinishedBefore)
+	}
+
+	result, err := a.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("can't purge finished jobs: %w: %v", ErrGeneric, err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("can't count purged jobs: %w: %v", ErrGeneric, err)
+	}
+
+	return count, nil
+}
+
+// transition moves a job to a new state on behalf of an operator. Running jobs
+// belong to the worker holding their lock, so they can't be changed from here.
+func (a *Admin) transition(id string, to State, update func(from State) (sql.Result, error)) error {
+	details, err := a.Lookup(id)
+	if err != nil {
+		return err
+	}
+
+	if details.State == StateRunning {
+		return fmt.Errorf("can't move job (id=%s) to %s: %w", id, to, ErrJobLocked)
+	}
+
+	if err := validateTransition(id, details.State, to); err != nil {
+		return err
+	}
+
+	result, err := update(details.State)
+	if err != nil {
+		return fmt.Errorf("can't move job (id=%s) from %s to %s: %w: %v", id, details.State, to, ErrGeneric, err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can't count updated jobs (id=%s): %w: %v", id, ErrGeneric, err)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("can't move job (id=%s) from %s to %s: job state changed concurrently: %w", id, details.State, to, ErrInvalidTransition)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func scanDetails(row scanner) (Details, error) {
+	var details Details
+	var params, at string
+	var lockedUntil, finishedAt, lastError, progressMsg, singletonKey, traceID sql.NullString
+
+	err := row.Scan(
+		&details.ID, &details.Name, &params, &at, &details.Attempts, &details.MaxAttempts,
+		&details.State, &lockedUntil, &finishedAt, &lastError, &details.Progress, &progressMsg, &singletonKey, &traceID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Details{}, err
+		}
+		return Details{}, fmt.Errorf("can't scan job: %w: %v", ErrGeneric, err)
+	}
+
+	details.Params = json.RawMessage(params)
+	details.LastError = lastError.String
+	details.ProgressMessage = progressMsg.String
+	details.SingletonKey = singletonKey.String
+	details.TraceID = traceID.String
+
+	if details.At, err = sqliteutil.ParseTime(at); err != nil {
+		return Details{}, fmt.Errorf("can't parse job schedule (id=%s): %w: %v", details.ID, ErrGeneric, err)
+	}
+
+	if details.LockedUntil, err = parseNullTime(lockedUntil); err != nil {
+		return Details{}, fmt.Errorf("can't parse job lock (id=%s): %w: %v", details.ID, ErrGeneric, err)
+	}
+
+	if details.FinishedAt, err = parseNullTime(finishedAt); err != nil {
+		return Details{}, fmt.Errorf("can't parse job end (id=%s): %w: %v", details.ID, ErrGeneric, err)
+	}
+
+	return details, nil
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	t, err := sqliteutil.ParseTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,147 @@
+package job_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // sqlite3 adapter
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/sqlutil"
+	"github.com/lonepeon/golib/testutils"
+)
+
+func TestIntegrationAdmin(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	t.Parallel()
+
+	t.Run("AdminListAll", testAdminListAll)
+	t.Run("AdminListByState", testAdminListByState)
+	t.Run("AdminLookupNotFound", testAdminLookupNotFound)
+	t.Run("AdminPurgeFinishedJobs", testAdminPurgeFinishedJobs)
+	t.Run("AdminPurgeUnfinishedJobs", testAdminPurgeUnfinishedJobs)
+}
+
+func testAdminListAll(t *testing.T) {
+	db := setupDatabase(t)
+	first := enqueueJob(t, db, "send-email", map[string]string{"to": "jane"})
+	second := enqueueJob(t, db, "send-sms", map[string]string{"to": "john"})
+
+	jobs, err := job.NewAdmin(db).List(job.ListFilter{})
+	testutils.RequireNoError(t, err, "can't list jobs")
+	testutils.RequireEqualInt(t, 2, len(jobs), "unexpected number of jobs")
+	testutils.AssertEqualString(t, first.ID(), jobs[0].ID, "unexpected first job")
+	testutils.AssertEqualString(t, "send-email", jobs[0].Name, "unexpected first job name")
+	testutils.AssertEqualString(t, `{"to":"jane"}`, string(jobs[0].Params), "unexpected first job params")
+	testutils.AssertEqualString(t, string(job.StateScheduled), string(jobs[0].State), "unexpected first job state")
+	testutils.AssertEqualString(t, second.ID(), jobs[1].ID, "unexpected second job")
+}
+
+func testAdminListByState(t *testing.T) {
+	db := setupDatabase(t)
+	scheduled := enqueueJob(t, db, "send-email", nil)
+	failed := enqueueJob(t, db, "send-email", nil)
+	running := enqueueJob(t, db, "send-email", nil)
+	setJobState(t, db, failed.ID(), job.StateFailed, time.Time{})
+	setJobState(t, db, running.ID(), job.StateRunning, time.Now().Add(time.Minute))
+
+	admin := job.NewAdmin(db)
+	for state, id := range map[job.State]string{
+		job.StateScheduled: scheduled.ID(),
+		job.StateFailed:    failed.ID(),
+		job.StateRunning:   running.ID(),
+	} {
+		jobs, err := admin.List(job.ListFilter{State: state})
+		testutils.RequireNoError(t, err, "can't list %s jobs", state)
+		testutils.RequireEqualInt(t, 1, len(jobs), "unexpected number of %s jobs", state)
+		testutils.AssertEqualString(t, id, jobs[0].ID, "unexpected %s job", state)
+	}
+}
+
+func testAdminLookupNotFound(t *testing.T) {
+	db := setupDatabase(t)
+
+	_, err := job.NewAdmin(db).Lookup("unknown")
+	testutils.AssertErrorIs(t, job.ErrJobNotFound, err, "unexpected error")
+}
+
+func testAdminPurgeFinishedJobs(t *testing.T) {
+	db := setupDatabase(t)
+	enqueueJob(t, db, "send-email", nil)
+	failed := enqueueJob(t, db, "send-email", nil)
+	succeeded := enqueueJob(t, db, "send-email", nil)
+	otherFailed := enqueueJob(t, db, "send-sms", nil)
+	setJobState(t, db, failed.ID(), job.StateFailed, time.Time{})
+	setJobState(t, db, succeeded.ID(), job.StateSucceeded, time.Time{})
+	setJobState(t, db, otherFailed.ID(), job.StateFailed, time.Time{})
+
+	admin := job.NewAdmin(db)
+	count, err := admin.Purge(job.PurgeFilter{Name: "send-email"})
+	testutils.RequireNoError(t, err, "can't purge jobs")
+	testutils.AssertEqualInt64(t, 2, count, "unexpected number of purged jobs")
+
+	jobs, err := admin.List(job.ListFilter{})
+	testutils.RequireNoError(t, err, "can't list jobs")
+	testutils.AssertEqualInt(t, 2, len(jobs), "unexpected number of remaining jobs")
+}
+
+func testAdminPurgeUnfinishedJobs(t *testing.T) {
+	db := setupDatabase(t)
+	enqueueJob(t, db, "send-email", nil)
+
+	_, err := job.NewAdmin(db).Purge(job.PurgeFilter{State: job.StateScheduled})
+	testutils.AssertErrorContains(t, "only finished jobs", err, "unexpected error")
+}
+
+func enqueueJob(t *testing.T, db *sql.DB, name string, params interface{}) job.Job {
+	j, err := job.NewJob(name, params)
+	testutils.RequireNoError(t, err, "can't build job")
+	j.At = j.At.Add(-time.Minute)
+
+	testutils.RequireNoError(t, job.NewClient(db).Enqueue(j), "can't enqueue job")
+
+	// ensure jobs are ordered by insertion
+	time.Sleep(time.Millisecond)
+
+	return j
+}
+
+func setJobState(t *testing.T, db *sql.DB, id string, state job.State, lockedUntil time.Time) {
+	var lock interface{}
+	if !lockedUntil.IsZero() {
+		lock = lockedUntil
+	}
+
+	var finishedAt interface{}
+	if state.IsFinal() {
+		finishedAt = time.Now()
+	}
+
+	_, err := db.Exec(`UPDATE jobs SET state = ?, locked_until = ?, finished_at = ? WHERE id = ?`, state, lock, finishedAt, id)
+	testutils.RequireNoError(t, err, "can't set job state")
+}
+
+func setupDatabase(t *testing.T) *sql.DB {
+	f, err := ioutil.TempFile("", "job-*.sqlite")
+	testutils.RequireNoError(t, err, "can't create SQLite temporary file")
+
+	db, err := sql.Open("sqlite3", f.Name())
+	testutils.RequireNoError(t, err, "can't open sqlite connection")
+
+	_, err = sqlutil.ExecuteMigrations(context.Background(), db, job.Migrations())
+	testutils.RequireNoError(t, err, "can't run migrations")
+
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(f.Name())
+	})
+
+	return db
+}
@@ -1,19 +1,42 @@
 package job
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+
+	"github.com/lonepeon/golib/logger"
 )
 
+type Enqueuer interface {
+	Enqueue(Job) error
+	EnqueueContext(context.Context, Job) error
+}
+
 type Client struct {
 	db *sql.DB
 }
 
+func NewClient(db *sql.DB) *Client {
+	return &Client{db: db}
+}
+
 func (c *Client) Enqueue(job Job) error {
-	_, err := c.db.Exec(`
-		INSERT INTO jobs (id, name, params, at, attempts, max_attempts)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, job.id, job.Name, job.params, job.At, job.attempts, job.MaxAttempts,
+	return c.EnqueueContext(context.Background(), job)
+}
+
+// EnqueueContext enqueues job with the trace ID carried by ctx, unless the job
+// already has one.
+func (c *Client) EnqueueContext(ctx context.Context, job Job) error {
+	if job.TraceID == "" {
+		job.TraceID = logger.TraceIDFromContext(ctx)
+	}
+
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO jobs (id, name, params, at, attempts, max_attempts, state, singleton_key, trace_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, job.id, job.Name, job.params, job.At, job.attempts, job.MaxAttempts, StateScheduled, nullString(job.SingletonKey), nullString(job.TraceID),
 	)
 
 	if err != nil {
@@ -22,3 +45,25 @@ func (c *Client) Enqueue(job Job) error {
 
 	return nil
 }
+
+func (c *Client) Progress(id string) (Progress, error) {
+	row := c.db.QueryRow(`SELECT state, progress, progress_message FROM jobs WHERE id = $1`, id)
+
+	var progress Progress
+	var message sql.NullString
+	if err := row.Scan(&progress.State, &progress.Percent, &message); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Progress{}, fmt.Errorf("can't get job progress (id=%s): %w", id, ErrJobNotFound)
+		}
+		return Progress{}, fmt.Errorf("can't get job progress (id=%s): %w: %v", id, ErrGeneric, err)
+	}
+	progress.Message = message.String
+
+	return progress, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+var _ Enqueuer = &Client{}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // sqlite3 adapter
+
+	"github.com/lonepeon/golib/job"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+type command func(db *sql.DB, args []string, w io.Writer) error
+
+var commands = map[string]command{
+	"list":    list,
+	"inspect": inspect,
+	"retry":   retry,
+	"cancel":  cancel,
+	"purge":   purge,
+	"enqueue": enqueue,
+}
+
+func usage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "usage: job-admin -db <path> <list|inspect|retry|cancel|purge|enqueue> [options]\n")
+		flags.PrintDefaults()
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	var dbPath string
+
+	flags := flag.NewFlagSet("job-admin", flag.ContinueOnError)
+	flags.StringVar(&dbPath, "db", os.Getenv("JOB_ADMIN_DB"), "path to the SQLite database (env JOB_ADMIN_DB)")
+	flags.Usage = usage(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if dbPath == "" {
+		return fmt.Errorf("database path is required")
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command (command=%s)", name)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("can't open database (path=%s): %v", dbPath, err)
+	}
+	defer db.Close()
+
+	return cmd(db, flags.Args()[1:], w)
+}
+
+func list(db *sql.DB, args []string, w io.Writer) error {
+	var filter job.ListFilter
+	var state, format string
+
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.StringVar(&filter.Name, "name", "", "only list jobs with this name")
+	flags.StringVar(&state, "state", "", "only list jobs in this state (scheduled, running, succeeded, failed, cancelled)")
+	flags.IntVar(&filter.Limit, "limit", 50, "maximum number of jobs to list (0 means no limit)")
+	flags.StringVar(&format, "format", "table", "output format (table, json)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := parseState(state, &filter.State); err != nil {
+		return err
+	}
+
+	jobs, err := job.NewAdmin(db).List(filter)
+	if err != nil {
+		return err
+	}
+
+	if jobs == nil {
+		jobs = []job.Details{}
+	}
+
+	return output(w, format, jobs, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tNAME\tSTATE\tAT\tATTEMPTS")
+		for _, j := range jobs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d/%d\n", j.ID, j.Name, j.State, j.At.Format(time.RFC3339), j.Attempts, j.MaxAttempts)
+		}
+	})
+}
+
+func inspect(db *sql.DB, args []string, w io.Writer) error {
+	var format string
+
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.StringVar(&format, "format", "table", "output format (table, json)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id, err := requiredArg(flags, "job id")
+	if err != nil {
+		return err
+	}
+
+	details, err := job.NewAdmin(db).Lookup(id)
+	if err != nil {
+		return err
+	}
+
+	return output(w, format, details, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "ID\t%s\n", details.ID)
+		fmt.Fprintf(tw, "NAME\t%s\n", details.Name)
+		fmt.Fprintf(tw, "STATE\t%s\n", details.State)
+		fmt.Fprintf(tw, "AT\t%s\n", details.At.Format(time.RFC3339))
+		fmt.Fprintf(tw, "ATTEMPTS\t%d/%d\n", details.Attempts, details.MaxAttempts)
+		fmt.Fprintf(tw, "SINGLETON KEY\t%s\n", formatOptionalString(details.SingletonKey))
+		fmt.Fprintf(tw, "TRACE ID\t%s\n", formatOptionalString(details.TraceID))
+		fmt.Fprintf(tw, "LOCKED UNTIL\t%s\n", formatOptionalTime(details.LockedUntil))
+		fmt.Fprintf(tw, "FINISHED AT\t%s\n", formatOptionalTime(details.FinishedAt))
+		fmt.Fprintf(tw, "LAST ERROR\t%s\n", formatOptionalString(details.LastError))
+		fmt.Fprintf(tw, "PROGRESS\t%d%% %s\n", details.Progress, details.ProgressMessage)
+		fmt.Fprintf(tw, "PARAMS\t%s\n", string(details.Params))
+	})
+}
+
+func retry(db *sql.DB, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("retry", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id, err := requiredArg(flags, "job id")
+	if err != nil {
+		return err
+	}
+
+	if err := job.NewAdmin(db).Retry(id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "job %s rescheduled\n", id)
+	return nil
+}
+
+func cancel(db *sql.DB, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id, err := requiredArg(flags, "job id")
+	if err != nil {
+		return err
+	}
+
+	if err := job.NewAdmin(db).Cancel(id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "job %s cancelled\n", id)
+	return nil
+}
+
+func purge(db *sql.DB, args []string, w io.Writer) error {
+	var filter job.PurgeFilter
+	var state string
+	var olderThan time.Duration
+
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	flags.StringVar(&filter.Name, "name", "", "only purge jobs with this name")
+	flags.StringVar(&state, "state", "", "only purge jobs in this state (succeeded, failed, cancelled)")
+	flags.DurationVar(&olderThan, "older-than", 0, "only purge jobs which finished before this duration")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := parseState(state, &filter.State); err != nil {
+		return err
+	}
+
+	if olderThan > 0 {
+		filter.FinishedBefore = time.Now().Add(-olderThan)
+	}
+
+	count, err := job.NewAdmin(db).Purge(filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d finished jobs purged\n", count)
+	return nil
+}
+
+func enqueue(db *sql.DB, args []string, w io.Writer) error {
+	var params, singletonKey string
+	var in time.Duration
+	var maxAttempts int
+
+	flags := flag.NewFlagSet("enqueue", flag.ContinueOnError)
+	flags.StringVar(&params, "params", "{}", "JSON encoded job params")
+	flags.DurationVar(&in, "in", 0, "delay before the job is executed")
+	flags.IntVar(&maxAttempts, "max-attempts", job.DefaultMaxAttempts, "maximum number of attempts")
+	flags.StringVar(&singletonKey, "singleton-key", "", "prevent jobs sharing this key from running concurrently")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	name, err := requiredArg(flags, "job name")
+	if err != nil {
+		return err
+	}
+
+	if !json.Valid([]byte(params)) {
+		return fmt.Errorf("params are not valid JSON (params=%s)", params)
+	}
+
+	j, err := job.NewJob(name, json.RawMessage(params))
+	if err != nil {
+		return err
+	}
+	j.At = j.At.Add(in)
+	j.MaxAttempts = maxAttempts
+	j.SingletonKey = singletonKey
+
+	if err := job.NewClient(db).Enqueue(j); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "job %s enqueued\n", j.ID())
+	return nil
+}
+
+func requiredArg(flags *flag.FlagSet, name string) (string, error) {
+	value := strings.TrimSpace(flags.Arg(0))
+	if value == "" {
+		return "", fmt.Errorf("%s is required as first argument", name)
+	}
+
+	return value, nil
+}
+
+func output(w io.Writer, format string, value interface{}, table func(*tabwriter.Writer)) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("can't encode output to json: %v", err)
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		table(tw)
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("can't write table output: %v", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format (format=%s)", format)
+	}
+}
+
+func parseState(value string, state *job.State) error {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := job.ParseState(value)
+	if err != nil {
+		return err
+	}
+
+	*state = parsed
+	return nil
+}
+
+func formatOptionalString(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -19,10 +19,20 @@ type Job struct {
 	Name        string
 	At          time.Time
 	MaxAttempts int
+	// SingletonKey prevents two jobs sharing the same key from running at the
+	// same time across all servers. Registry.RegisterSingleton makes a whole kind of job exclusive.
+	SingletonKey string
+	// TraceID correlates the job with the request which enqueued it. It's set
+	// by Client.EnqueueContext and logged with every job execution.
+	TraceID string
 
 	id       string
 	params   []byte
 	attempts int
+	state    State
+	// lockID identifies the execution which dequeued the job, so a worker
+	// whose lock expired can't update a job reclaimed by another one.
+	lockID string
 }
 
 func NewJob(name string, params interface{}) (Job, error) {
@@ -41,9 +51,18 @@ func NewJob(name string, params interface{}) (Job, error) {
 		id:       id,
 		attempts: 1,
 		params:   p,
+		state:    StateScheduled,
 	}, nil
 }
 
+func (j Job) ID() string {
+	return j.id
+}
+
+func (j Job) State() State {
+	return j.state
+}
+
 func (j Job) EncodedParams() []byte {
 	return j.params
 }
@@ -20,6 +20,61 @@ func Migrations() []sqlutil.Migration {
   failed TEXT
 )
 
+`,
+		},
+		{
+			Version: "202610191420",
+			Script: `ALTER TABLE jobs ADD COLUMN state TEXT NOT NULL DEFAULT 'scheduled';
+ALTER TABLE jobs ADD COLUMN finished_at TEXT;
+ALTER TABLE jobs ADD COLUMN last_error TEXT;
+
+UPDATE jobs
+SET state = 'failed', finished_at = failed
+WHERE failed IS NOT NULL;
+
+UPDATE jobs
+SET state = 'failed', finished_at = COALESCE(locked_until, at), last_error = 'attempts exhausted before state migration'
+WHERE failed IS NULL AND attempts >= max_attempts;
+
+UPDATE jobs
+SET state = 'running'
+WHERE state = 'scheduled' AND locked_until IS NOT NULL;
+
+ALTER TABLE jobs DROP COLUMN failed;
+
+CREATE INDEX jobs_state_at ON jobs(state, at);
+
+`,
+		},
+		{
+			Version: "202610191500",
+			Script: `ALTER TABLE jobs ADD COLUMN progress INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN progress_message TEXT;
+
+`,
+		},
+		{
+			Version: "202610191600",
+			Script: `ALTER TABLE jobs ADD COLUMN singleton_key TEXT;
+
+CREATE TABLE job_leases (
+  key TEXT PRIMARY KEY,
+  job_id TEXT NOT NULL,
+  expires_at TEXT NOT NULL
+);
+
+`,
+		},
+		{
+			Version: "202610191700",
+			Script: `ALTER TABLE jobs ADD COLUMN trace_id TEXT;
+
+`,
+		},
+		{
+			Version: "202610191800",
+			Script: `ALTER TABLE jobs ADD COLUMN lock_id TEXT;
+
 `,
 		},
 	}
@@ -0,0 +1,50 @@
+package jobtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func AssertEnqueued(t *testing.T, client *Client, name string, params gomock.Matcher, format string, args ...interface{}) {
+	t.Helper()
+
+	jobs := client.JobsNamed(name)
+	for _, j := range jobs {
+		if params.Matches(j) {
+			return
+		}
+	}
+
+	t.Errorf("%s: no job enqueued with name=%s matching %s\nenqueued jobs:\n%s", fmt.Sprintf(format, args...), name, params, describeJobs(client))
+}
+
+func AssertNotEnqueued(t *testing.T, client *Client, name string, format string, args ...interface{}) {
+	t.Helper()
+
+	if jobs := client.JobsNamed(name); len(jobs) != 0 {
+		t.Errorf("%s: expected no job enqueued with name=%s but got %d\nenqueued jobs:\n%s", fmt.Sprintf(format, args...), name, len(jobs), describeJobs(client))
+	}
+}
+
+func AssertEnqueuedCount(t *testing.T, client *Client, want int, format string, args ...interface{}) {
+	t.Helper()
+
+	if got := len(client.Jobs()); want != got {
+		t.Errorf("%s: expected %d enqueued jobs but got %d\nenqueued jobs:\n%s", fmt.Sprintf(format, args...), want, got, describeJobs(client))
+	}
+}
+
+func describeJobs(client *Client) string {
+	var description string
+	for _, j := range client.Jobs() {
+		description += fmt.Sprintf("- name=%s, params=%s\n", j.Name, string(j.EncodedParams()))
+	}
+
+	if description == "" {
+		return "<none>"
+	}
+
+	return description
+}
@@ -0,0 +1,70 @@
+package jobtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/logger"
+)
+
+type Client struct {
+	l    *sync.RWMutex
+	jobs []job.Job
+
+	Err error
+}
+
+func NewClient() *Client {
+	return &Client{l: &sync.RWMutex{}}
+}
+
+func (c *Client) Enqueue(j job.Job) error {
+	if c.Err != nil {
+		return c.Err
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.jobs = append(c.jobs, j)
+
+	return nil
+}
+
+// EnqueueContext records j with the trace ID of ctx, like job.Client does.
+func (c *Client) EnqueueContext(ctx context.Context, j job.Job) error {
+	if j.TraceID == "" {
+		j.TraceID = logger.TraceIDFromContext(ctx)
+	}
+
+	return c.Enqueue(j)
+}
+
+func (c *Client) Jobs() []job.Job {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	jobs := make([]job.Job, len(c.jobs))
+	copy(jobs, c.jobs)
+
+	return jobs
+}
+
+func (c *Client) JobsNamed(name string) []job.Job {
+	var jobs []job.Job
+	for _, j := range c.Jobs() {
+		if j.Name == name {
+			jobs = append(jobs, j)
+		}
+	}
+
+	return jobs
+}
+
+func (c *Client) Reset() {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.jobs = nil
+}
+
+var _ job.Enqueuer = &Client{}
@@ -0,0 +1,38 @@
+package jobtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lonepeon/golib/job"
+)
+
+func RunHandler(t *testing.T, handler job.HandlerFunc, params interface{}) error {
+	t.Helper()
+
+	_, err := RunHandlerWithProgress(t, handler, params)
+	return err
+}
+
+func RunHandlerWithProgress(t *testing.T, handler job.HandlerFunc, params interface{}) (*ProgressRecorder, error) {
+	t.Helper()
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("can't marshal job params to json: %v", err)
+	}
+
+	recorder := NewProgressRecorder()
+	ctx := job.WithProgressReporter(context.Background(), recorder)
+
+	return recorder, handler(ctx, encoded)
+}
+
+func RunJob(t *testing.T, handler job.HandlerFunc, j job.Job) error {
+	t.Helper()
+
+	ctx := job.WithProgressReporter(context.Background(), NewProgressRecorder())
+
+	return handler(ctx, j.EncodedParams())
+}
@@ -0,0 +1,94 @@
+package jobtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/lonepeon/golib/job"
+)
+
+func MatchJobName(name string) _GoMockJobName {
+	return _GoMockJobName{name: name}
+}
+
+func MatchJobParams(params interface{}) _GoMockJobParams {
+	return _GoMockJobParams{params: params}
+}
+
+func MatchJob(name string, params interface{}) _GoMockJob {
+	return _GoMockJob{name: MatchJobName(name), params: MatchJobParams(params)}
+}
+
+type _GoMockJobName struct {
+	name string
+}
+
+func (m _GoMockJobName) Matches(v interface{}) bool {
+	j, ok := v.(job.Job)
+	if !ok {
+		return false
+	}
+
+	return j.Name == m.name
+}
+
+func (m _GoMockJobName) String() string {
+	return fmt.Sprintf("job name=%s", m.name)
+}
+
+type _GoMockJobParams struct {
+	params interface{}
+}
+
+// Matches compares the JSON representations of both params so a struct
+// can be matched against the map it was encoded from, and vice-versa.
+func (m _GoMockJobParams) Matches(v interface{}) bool {
+	j, ok := v.(job.Job)
+	if !ok {
+		return false
+	}
+
+	want, err := normalizeJSON(m.params)
+	if err != nil {
+		return false
+	}
+
+	var got interface{}
+	if err := json.Unmarshal(j.EncodedParams(), &got); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(want, got)
+}
+
+func (m _GoMockJobParams) String() string {
+	return fmt.Sprintf("job params=%#+v", m.params)
+}
+
+type _GoMockJob struct {
+	name   _GoMockJobName
+	params _GoMockJobParams
+}
+
+func (m _GoMockJob) Matches(v interface{}) bool {
+	return m.name.Matches(v) && m.params.Matches(v)
+}
+
+func (m _GoMockJob) String() string {
+	return fmt.Sprintf("%s, %s", m.name, m.params)
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,3 @@
+package jobtest
+
+//go:generate mockgen -destination=job.go -package jobtest github.com/lonepeon/golib/job Enqueuer
@@ -0,0 +1,45 @@
+package jobtest
+
+import (
+	"sync"
+
+	"github.com/lonepeon/golib/job"
+)
+
+type ProgressRecorder struct {
+	l       *sync.RWMutex
+	reports []job.Progress
+}
+
+func NewProgressRecorder() *ProgressRecorder {
+	return &ProgressRecorder{l: &sync.RWMutex{}}
+}
+
+func (r *ProgressRecorder) ReportProgress(percent int, message string) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.reports = append(r.reports, job.Progress{State: job.StateRunning, Percent: percent, Message: message})
+
+	return nil
+}
+
+func (r *ProgressRecorder) Reports() []job.Progress {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	reports := make([]job.Progress, len(r.reports))
+	copy(reports, r.reports)
+
+	return reports
+}
+
+func (r *ProgressRecorder) Last() (job.Progress, bool) {
+	reports := r.Reports()
+	if len(reports) == 0 {
+		return job.Progress{}, false
+	}
+
+	return reports[len(reports)-1], true
+}
+
+var _ job.ProgressReporter = &ProgressRecorder{}
@@ -0,0 +1,81 @@
+package job
+
+import (
+	"fmt"
+	"time"
+)
+
+// acquireLease reserves the job singleton key cluster-wide. The lease is
+// granted if nobody holds it, if it expired or if the job already owns it.
+func (s *Server) acquireLease(now time.Time, job Job) (bool, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO job_leases (key, job_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET job_id = excluded.job_id, expires_at = excluded.expires_at
+		WHERE job_leases.expires_at <= $4 OR job_leases.job_id = excluded.job_id`,
+		job.SingletonKey, job.id, now.Add(s.LeaseDuration), now)
+	if err != nil {
+		return false, fmt.Errorf("can't acquire lease (key=%s, id=%s): %w: %v", job.SingletonKey, job.id, ErrGeneric, err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can't count acquired leases (key=%s, id=%s): %w: %v", job.SingletonKey, job.id, ErrGeneric, err)
+	}
+
+	return count == 1, nil
+}
+
+func (s *Server) releaseLease(job Job) error {
+	_, err := s.db.Exec(`DELETE FROM job_leases WHERE key = $1 AND job_id = $2`, job.SingletonKey, job.id)
+	if err != nil {
+		return fmt.Errorf("can't release lease (key=%s, id=%s): %w: %v", job.SingletonKey, job.id, ErrGeneric, err)
+	}
+
+	return nil
+}
+
+// keepAlive extends the job lock, and its lease if any, while its handler
+// is running so long jobs are not reclaimed by another worker.
+func (s *Server) keepAlive(job Job) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(s.LeaseDuration / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := s.extendLock(now, job); err != nil {
+					s.log.Error(err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (s *Server) extendLock(now time.Time, job Job) error {
+	until := now.Add(s.LeaseDuration)
+
+	_, err := s.db.Exec(`UPDATE jobs SET locked_until = $1 WHERE id = $2 AND state = $3 AND lock_id = $4`, until, job.id, StateRunning, job.lockID)
+	if err != nil {
+		return fmt.Errorf("can't extend job lock (id=%s): %w: %v", job.id, ErrGeneric, err)
+	}
+
+	if job.SingletonKey == "" {
+		return nil
+	}
+
+	_, err = s.db.Exec(`UPDATE job_leases SET expires_at = $1 WHERE key = $2 AND job_id = $3`, until, job.SingletonKey, job.id)
+	if err != nil {
+		return fmt.Errorf("can't extend lease (key=%s, id=%s): %w: %v", job.SingletonKey, job.id, ErrGeneric, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package job_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/sqlutil/sqliteutil"
+	"github.com/lonepeon/golib/testutils"
+)
+
+func TestIntegrationLease(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	t.Parallel()
+
+	t.Run("SingletonJobsDontRunConcurrently", testSingletonJobsDontRunConcurrently)
+	t.Run("SingletonJobsWithDifferentKeysRunConcurrently", testSingletonJobsWithDifferentKeysRunConcurrently)
+	t.Run("SingletonKeyIsSharedAcrossJobNames", testSingletonKeyIsSharedAcrossJobNames)
+	t.Run("RegisteredSingletonJobsDontRunConcurrently", testRegisteredSingletonJobsDontRunConcurrently)
+	t.Run("WaitingRegisteredSingletonJobDoesntBlockQueue", testWaitingRegisteredSingletonJobDoesntBlockQueue)
+	t.Run("ExpiredLeaseIsTakenOver", testExpiredLeaseIsTakenOver)
+	t.Run("LeaseIsReleasedAfterFailure", testLeaseIsReleasedAfterFailure)
+	t.Run("LeaseIsExtendedWhileRunning", testLeaseIsExtendedWhileRunning)
+}
+
+func testSingletonJobsDontRunConcurrently(t *testing.T) {
+	db := setupDatabase(t)
+	other := setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error { return nil },
+	})
+
+	var processedConcurrently bool
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error {
+			processedConcurrently = other.ProcessNextJob()
+			return nil
+		},
+	})
+
+	first := enqueueSingletonJob(t, db, "cleanup", "cleanup")
+	second := enqueueSingletonJob(t, db, "cleanup", "cleanup")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected first job to be processed")
+	testutils.AssertEqualBool(t, false, processedConcurrently, "expected second job to wait for the lease")
+	assertJobState(t, db, first.ID(), job.StateSucceeded)
+	assertJobState(t, db, second.ID(), job.StateScheduled)
+
+	testutils.RequireEqualBool(t, true, other.ProcessNextJob(), "expected second job to be processed once the lease is released")
+	assertJobState(t, db, second.ID(), job.StateSucceeded)
+}
+
+func testSingletonJobsWithDifferentKeysRunConcurrently(t *testing.T) {
+	db := setupDatabase(t)
+	other := setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error { return nil },
+	})
+
+	var processedConcurrently bool
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error {
+			processedConcurrently = other.ProcessNextJob()
+			return nil
+		},
+	})
+
+	enqueueSingletonJob(t, db, "cleanup", "tenant-1")
+	enqueueSingletonJob(t, db, "cleanup", "tenant-2")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected first job to be processed")
+	testutils.AssertEqualBool(t, true, processedConcurrently, "expected second job to run concurrently")
+}
+
+func testSingletonKeyIsSharedAcrossJobNames(t *testing.T) {
+	db := setupDatabase(t)
+	other := setupServer(t, db, map[string]job.HandlerFunc{
+		"reindex": func(context.Context, []byte) error { return nil },
+	})
+
+	var processedConcurrently bool
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"vacuum": func(context.Context, []byte) error {
+			processedConcurrently = other.ProcessNextJob()
+			return nil
+		},
+	})
+
+	enqueueSingletonJob(t, db, "vacuum", "maintenance")
+	enqueueSingletonJob(t, db, "reindex", "maintenance")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected first job to be processed")
+	testutils.AssertEqualBool(t, false, processedConcurrently, "expected second job to wait for the lease")
+}
+
+func testRegisteredSingletonJobsDontRunConcurrently(t *testing.T) {
+	db := setupDatabase(t)
+	other := setupSingletonServer(t, db, "cleanup", func(context.Context, []byte) error { return nil }, nil)
+
+	var processedConcurrently bool
+	server := setupSingletonServer(t, db, "cleanup", func(context.Context, []byte) error {
+		processedConcurrently = other.ProcessNextJob()
+		return nil
+	}, nil)
+
+	first := enqueueJob(t, db, "cleanup", nil)
+	second := enqueueJob(t, db, "cleanup", nil)
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected first job to be processed")
+	testutils.AssertEqualBool(t, false, processedConcurrently, "expected second job to wait for the lease")
+	assertJobState(t, db, first.ID(), job.StateSucceeded)
+	assertJobState(t, db, second.ID(), job.StateScheduled)
+}
+
+func testWaitingRegisteredSingletonJobDoesntBlockQueue(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupSingletonServer(t, db, "cleanup", func(context.Context, []byte) error { return nil }, map[string]job.HandlerFunc{
+		"other": func(context.Context, []byte) error { return nil },
+	})
+
+	_, err := db.Exec(`INSERT INTO job_leases (key, job_id, expires_at) VALUES ('cleanup', 'running-elsewhere', ?)`, time.Now().Add(time.Minute))
+	testutils.RequireNoError(t, err, "can't insert active lease")
+
+	singleton := enqueueJob(t, db, "cleanup", nil)
+	other := enqueueJob(t, db, "other", nil)
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected the job behind the singleton to be processed")
+	assertJobState(t, db, other.ID(), job.StateSucceeded)
+	assertJobState(t, db, singleton.ID(), job.StateScheduled)
+}
+
+func testExpiredLeaseIsTakenOver(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error { return nil },
+	})
+
+	_, err := db.Exec(`INSERT INTO job_leases (key, job_id, expires_at) VALUES ('cleanup', 'crashed-job', ?)`, time.Now().Add(-time.Second))
+	testutils.RequireNoError(t, err, "can't insert expired lease")
+
+	j := enqueueSingletonJob(t, db, "cleanup", "cleanup")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected job to be processed")
+	assertJobState(t, db, j.ID(), job.StateSucceeded)
+}
+
+func testLeaseIsReleasedAfterFailure(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{})
+
+	j := enqueueSingletonJob(t, db, "cleanup", "cleanup")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected job to be processed")
+	assertJobState(t, db, j.ID(), job.StateFailed)
+	testutils.AssertEqualInt(t, 0, countLeases(t, db), "expected lease to be released")
+}
+
+func testLeaseIsExtendedWhileRunning(t *testing.T) {
+	db := setupDatabase(t)
+
+	var server *job.Server
+	var lockedUntil time.Time
+	server = setupServer(t, db, map[string]job.HandlerFunc{
+		"cleanup": func(context.Context, []byte) error {
+			time.Sleep(3 * server.LeaseDuration)
+
+			var expiresAt string
+			if err := db.QueryRow(`SELECT expires_at FROM job_leases WHERE key = 'cleanup'`).Scan(&expiresAt); err != nil {
+				return err
+			}
+
+			var err error
+			lockedUntil, err = sqliteutil.ParseTime(expiresAt)
+			return err
+		},
+	})
+	server.LeaseDuration = 100 * time.Millisecond
+
+	enqueueSingletonJob(t, db, "cleanup", "cleanup")
+
+	start := time.Now()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected job to be processed")
+	testutils.AssertEqualBool(t, true, lockedUntil.After(start.Add(2*server.LeaseDuration)), "expected lease to be extended: %v", lockedUntil)
+}
+
+func setupSingletonServer(t *testing.T, db *sql.DB, name string, handler job.HandlerFunc, handlers map[string]job.HandlerFunc) *job.Server {
+	log, _, closer := loggertest.NewFake(t)
+	t.Cleanup(closer)
+
+	registry := job.NewRegistry()
+	registry.RegisterSingletonFunc(name, handler)
+	for otherName, otherHandler := range handlers {
+		registry.RegisterFunc(otherName, otherHandler)
+	}
+
+	server := job.NewServer(db, registry, log)
+	server.SucceededJobsRetention = time.Hour
+
+	return server
+}
+
+func enqueueSingletonJob(t *testing.T, db *sql.DB, name string, key string) job.Job {
+	j, err := job.NewJob(name, nil)
+	testutils.RequireNoError(t, err, "can't build job")
+	j.At = j.At.Add(-time.Minute)
+	j.SingletonKey = key
+
+	testutils.RequireNoError(t, job.NewClient(db).Enqueue(j), "can't enqueue job")
+
+	// ensure jobs are ordered by insertion
+	time.Sleep(time.Millisecond)
+
+	return j
+}
+
+func assertJobState(t *testing.T, db *sql.DB, id string, want job.State) {
+	t.Helper()
+
+	details, err := job.NewAdmin(db).Lookup(id)
+	testutils.RequireNoError(t, err, "can't lookup job")
+	testutils.AssertEqualString(t, string(want), string(details.State), "unexpected job state")
+}
+
+func countLeases(t *testing.T, db *sql.DB) int {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM job_leases`).Scan(&count)
+	testutils.RequireNoError(t, err, "can't count leases")
+
+	return count
+}
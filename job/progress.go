@@ -0,0 +1,62 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type progressKey struct{}
+
+type Progress struct {
+	State   State  `json:"state"`
+	Percent int    `json:"percent"`
+	Message string `json:"message,omitempty"`
+}
+
+type ProgressReporter interface {
+	ReportProgress(percent int, message string) error
+}
+
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// ReportProgress lets a handler share how far it is in its work. It does
+// nothing when the handler is not executed by a Server, e.g. in unit tests.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("progress must be between 0 and 100 (progress=%d): %w", percent, ErrGeneric)
+	}
+
+	reporter, ok := ctx.Value(progressKey{}).(ProgressReporter)
+	if !ok {
+		return nil
+	}
+
+	return reporter.ReportProgress(percent, message)
+}
+
+type sqlProgressReporter struct {
+	ctx    context.Context
+	db     *sql.DB
+	id     string
+	lockID string
+}
+
+func (r sqlProgressReporter) ReportProgress(percent int, message string) error {
+	var msg interface{}
+	if message != "" {
+		msg = message
+	}
+
+	_, err := r.db.ExecContext(r.ctx, `
+		UPDATE jobs
+		SET progress = $1, progress_message = $2
+		WHERE id = $3 AND state = $4 AND lock_id = $5`, percent, msg, r.id, StateRunning, r.lockID)
+	if err != nil {
+		return fmt.Errorf("can't report job progress (id=%s): %w: %v", r.id, ErrGeneric, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package job_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/job/jobtest"
+	"github.com/lonepeon/golib/testutils"
+)
+
+func TestReportProgressWithoutReporter(t *testing.T) {
+	err := job.ReportProgress(context.Background(), 50, "halfway")
+	testutils.AssertNoError(t, err, "expected progress to be ignored")
+}
+
+func TestReportProgressOutOfRange(t *testing.T) {
+	recorder := jobtest.NewProgressRecorder()
+	ctx := job.WithProgressReporter(context.Background(), recorder)
+
+	for _, percent := range []int{-1, 101} {
+		err := job.ReportProgress(ctx, percent, "")
+		testutils.AssertErrorIs(t, job.ErrGeneric, err, "expected progress %d to be rejected", percent)
+	}
+
+	testutils.AssertEqualInt(t, 0, len(recorder.Reports()), "unexpected progress reports")
+}
+
+func TestReportProgress(t *testing.T) {
+	handler := func(ctx context.Context, _ []byte) error {
+		if err := job.ReportProgress(ctx, 10, "starting"); err != nil {
+			return err
+		}
+		return job.ReportProgress(ctx, 60, "importing")
+	}
+
+	recorder, err := jobtest.RunHandlerWithProgress(t, handler, nil)
+	testutils.RequireNoError(t, err, "unexpected handler error")
+	testutils.RequireEqualInt(t, 2, len(recorder.Reports()), "unexpected number of progress reports")
+
+	last, _ := recorder.Last()
+	testutils.AssertEqualInt(t, 60, last.Percent, "unexpected last progress")
+	testutils.AssertEqualString(t, "importing", last.Message, "unexpected last progress message")
+}
@@ -2,6 +2,7 @@ package job
 
 import (
 	"context"
+	"sort"
 	"sync"
 )
 
@@ -13,14 +14,16 @@ type Handler interface {
 type HandlerFunc func(context.Context, []byte) error
 
 type Registry struct {
-	registry map[string]HandlerFunc
-	l        *sync.RWMutex
+	registry   map[string]HandlerFunc
+	singletons map[string]bool
+	l          *sync.RWMutex
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		l:        &sync.RWMutex{},
-		registry: make(map[string]HandlerFunc),
+		l:          &sync.RWMutex{},
+		registry:   make(map[string]HandlerFunc),
+		singletons: make(map[string]bool),
 	}
 }
 
@@ -32,6 +35,21 @@ func (r *Registry) RegisterFunc(name string, handler HandlerFunc) {
 	r.l.Lock()
 	defer r.l.Unlock()
 	r.registry[name] = handler
+	delete(r.singletons, name)
+}
+
+// RegisterSingleton registers a job of which a single instance runs at a time
+// across all servers, as if every job of this name was enqueued with its name
+// as SingletonKey. A job enqueued with its own SingletonKey keeps it.
+func (r *Registry) RegisterSingleton(job Handler) {
+	r.RegisterSingletonFunc(job.Name(), job.Handle)
+}
+
+func (r *Registry) RegisterSingletonFunc(name string, handler HandlerFunc) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.registry[name] = handler
+	r.singletons[name] = true
 }
 
 func (r *Registry) Handler(name string) (HandlerFunc, bool) {
@@ -40,3 +58,29 @@ func (r *Registry) Handler(name string) (HandlerFunc, bool) {
 	h, ok := r.registry[name]
 	return h, ok
 }
+
+// singletonKey returns the key shared by the jobs named name when they are
+// registered as singletons.
+func (r *Registry) singletonKey(name string) string {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	if r.singletons[name] {
+		return name
+	}
+
+	return ""
+}
+
+// singletonNames returns the sorted names of the jobs registered as singletons.
+func (r *Registry) singletonNames() []string {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	names := make([]string, 0, len(r.singletons))
+	for name := range r.singletons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
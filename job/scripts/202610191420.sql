@@ -0,0 +1,19 @@
+ALTER TABLE jobs ADD COLUMN state TEXT NOT NULL DEFAULT 'scheduled';
+ALTER TABLE jobs ADD COLUMN finished_at TEXT;
+ALTER TABLE jobs ADD COLUMN last_error TEXT;
+
+UPDATE jobs
+SET state = 'failed', finished_at = failed
+WHERE failed IS NOT NULL;
+
+UPDATE jobs
+SET state = 'failed', finished_at = COALESCE(locked_until, at), last_error = 'attempts exhausted before state migration'
+WHERE failed IS NULL AND attempts >= max_attempts;
+
+UPDATE jobs
+SET state = 'running'
+WHERE state = 'scheduled' AND locked_until IS NOT NULL;
+
+ALTER TABLE jobs DROP COLUMN failed;
+
+CREATE INDEX jobs_state_at ON jobs(state, at);
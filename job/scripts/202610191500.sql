@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN progress INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN progress_message TEXT;
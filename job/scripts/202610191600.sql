@@ -0,0 +1,7 @@
+ALTER TABLE jobs ADD COLUMN singleton_key TEXT;
+
+CREATE TABLE job_leases (
+  key TEXT PRIMARY KEY,
+  job_id TEXT NOT NULL,
+  expires_at TEXT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN lock_id TEXT;
@@ -5,13 +5,18 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/lonepeon/golib/logger"
+	"github.com/lonepeon/golib/sqlutil/sqliteutil"
 )
 
 var (
-	ErrGeneric = errors.New("something wrong happened")
+	ErrGeneric          = errors.New("something wrong happened")
+	ErrServerNotRunning = errors.New("job server is not running")
 )
 
 type Server struct {
@@ -19,8 +24,14 @@ type Server struct {
 	db       *sql.DB
 	log      *logger.Logger
 	shutdown chan bool
+	running  int32
 
 	SleepDuration time.Duration
+	LeaseDuration time.Duration
+	// SucceededJobsRetention keeps succeeded jobs, and their progress, readable
+	// for the given duration before ListenAndServe purges them. Succeeded jobs
+	// are deleted right away when it's zero.
+	SucceededJobsRetention time.Duration
 }
 
 func NewServer(db *sql.DB, reg *Registry, log *logger.Logger) *Server {
@@ -30,17 +41,28 @@ func NewServer(db *sql.DB, reg *Registry, log *logger.Logger) *Server {
 		registry:      reg,
 		shutdown:      make(chan bool),
 		SleepDuration: 5 * time.Second,
+		LeaseDuration: 1 * time.Minute,
 	}
 }
 
+// Shutdown waits for the job being processed, if any, and stops
+// ListenAndServe. It gives up when ctx is done.
 func (s *Server) Shutdown(ctx context.Context) error {
-	s.shutdown <- true
-	return nil
+	select {
+	case s.shutdown <- true:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (s *Server) ListenAndServe() error {
+	atomic.StoreInt32(&s.running, 1)
+	defer atomic.StoreInt32(&s.running, 0)
+
 	for {
-		s.dequeue()
+		s.ProcessNextJob()
+		s.purgeSucceededJobs(time.Now())
 
 		select {
 		case <-s.shutdown:
@@ -50,45 +72,119 @@ func (s *Server) ListenAndServe() error {
 	}
 }
 
+// CheckHealth reports ErrServerNotRunning when ListenAndServe isn't polling
+// jobs, or an error when the database can't be reached.
+func (s *Server) CheckHealth(ctx context.Context) error {
+	if atomic.LoadInt32(&s.running) == 0 {
+		return ErrServerNotRunning
+	}
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("can't reach jobs database: %v", err)
+	}
+
+	return nil
+}
+
 func (c *Server) Client() *Client {
 	return &Client{db: c.db}
 }
 
-func (s *Server) dequeue() {
+// ProcessNextJob executes the next job due for execution, if any.
+// It returns false when no job was waiting to be processed.
+func (s *Server) ProcessNextJob() bool {
 	now := time.Now()
 
 	job, err := s.fetchNextJob(now)
 	if err != nil {
-		return
+		return false
+	}
+
+	if job.SingletonKey == "" {
+		job.SingletonKey = s.registry.singletonKey(job.Name)
+	}
+
+	if job.SingletonKey != "" {
+		if !s.acquireJobLease(now, job) {
+			return false
+		}
+		defer s.releaseJobLease(job)
 	}
 
 	handler, err := s.fetchJobHandler(now, job)
 	if err != nil {
-		return
+		return true
 	}
 
-	if err = s.executeJobHandler(now, handler, job); err != nil {
-		return
+	stopKeepAlive := s.keepAlive(job)
+	defer stopKeepAlive()
+
+	_ = s.executeJobHandler(now, handler, job)
+
+	return true
+}
+
+// acquireJobLease puts the job back in the queue, without consuming an
+// attempt, when another job holding the same singleton key is running.
+func (s *Server) acquireJobLease(now time.Time, job Job) bool {
+	acquired, err := s.acquireLease(now, job)
+	if err != nil {
+		s.log.Error(err.Error())
+	}
+
+	if acquired {
+		return true
+	}
+
+	if err := s.transition(now, job, StateScheduled, ""); err != nil {
+		s.log.Error(fmt.Sprintf("can't release job waiting for its lease (id=%s, key=%s): %v", job.id, job.SingletonKey, err))
+	}
+
+	return false
+}
+
+func (s *Server) releaseJobLease(job Job) {
+	if err := s.releaseLease(job); err != nil {
+		s.log.Error(err.Error())
 	}
 }
 
 func (s *Server) fetchNextJob(now time.Time) (Job, error) {
+	args := []interface{}{StateRunning, now.Add(s.LeaseDuration), uuid.NewString(), StateScheduled, now}
+
+	// jobs registered as singletons don't carry their key in the database, it
+	// must be computed so a job waiting for its lease doesn't block the queue.
+	leaseKey := "jobs.singleton_key"
+	if names := s.registry.singletonNames(); len(names) > 0 {
+		placeholders := make([]string, 0, len(names))
+		for _, name := range names {
+			args = append(args, name)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		leaseKey = fmt.Sprintf("COALESCE(jobs.singleton_key, CASE WHEN jobs.name IN (%s) THEN jobs.name END)", strings.Join(placeholders, ", "))
+	}
+
 	row := s.db.QueryRow(`
 			UPDATE jobs
-			SET locked_until = $1
+			SET state = $1, locked_until = $2, lock_id = $3
 			WHERE id = (
 				SELECT id
 				FROM jobs
-				WHERE (locked_until IS NULL OR locked_until <= $2)
-					AND attempts < max_attempts
-					AND at <= $2
-					AND failed IS NULL
+				WHERE ((state = $4 AND at <= $5) OR (state = $1 AND locked_until <= $5))
+					AND NOT EXISTS (
+						SELECT 1
+						FROM job_leases
+						WHERE job_leases.key = `+leaseKey+`
+							AND job_leases.job_id != jobs.id
+							AND job_leases.expires_at > $5)
 				ORDER BY at ASC
 				LIMIT 1)
-			RETURNING id, name, params, attempts, max_attempts`, now.Add(1*time.Minute), now)
+			RETURNING id, name, params, at, attempts, max_attempts, state, singleton_key, trace_id, lock_id`, args...)
 
 	var job Job
-	if err := row.Scan(&job.id, &job.Name, &job.params, &job.attempts, &job.MaxAttempts); err != nil {
+	var at string
+	var singletonKey, traceID sql.NullString
+	if err := row.Scan(&job.id, &job.Name, &job.params, &at, &job.attempts, &job.MaxAttempts, &job.state, &singletonKey, &traceID, &job.lockID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Job{}, err
 		}
@@ -97,6 +193,15 @@ func (s *Server) fetchNextJob(now time.Time) (Job, error) {
 		return Job{}, err
 	}
 
+	scheduledAt, err := sqliteutil.ParseTime(at)
+	if err != nil {
+		s.log.Error(fmt.Sprintf("can't parse job schedule (id=%s): %v", job.id, err))
+		return Job{}, err
+	}
+	job.At = scheduledAt
+	job.SingletonKey = singletonKey.String
+	job.TraceID = traceID.String
+
 	return job, nil
 }
 
@@ -104,7 +209,7 @@ func (s *Server) fetchJobHandler(now time.Time, job Job) (HandlerFunc, error) {
 	handler, ok := s.registry.Handler(job.Name)
 	if !ok {
 		s.log.Error(fmt.Sprintf("can't find registered handler for job (id=%s, name=%s, params=%#+v)", job.id, job.Name, string(job.params)))
-		if _, err := s.db.Exec(`UPDATE jobs SET failed = $1 WHERE id = $2`, now, job.id); err != nil {
+		if err := s.transition(now, job, StateFailed, "handler not found"); err != nil {
 			s.log.Error(fmt.Sprintf("can't mark job as failed (id=%s, name=%s, params=%#+v): %v", job.id, job.Name, string(job.params), err))
 		}
 		return nil, fmt.Errorf("handler not found")
@@ -115,18 +220,24 @@ func (s *Server) fetchJobHandler(now time.Time, job Job) (HandlerFunc, error) {
 
 func (s *Server) executeJobHandler(now time.Time, handler HandlerFunc, job Job) error {
 	log := s.log.WithFields(logger.String("request-id", job.id))
+	ctx := WithProgressReporter(context.Background(), sqlProgressReporter{ctx: context.Background(), db: s.db, id: job.id, lockID: job.lockID})
+	if job.TraceID != "" {
+		log = log.WithFields(logger.String("trace-id", job.TraceID))
+		ctx = logger.ContextWithTraceID(ctx, job.TraceID)
+	}
+
 	log.Info(fmt.Sprintf("executing job handler (id=%s, name=%s, params=%#+v)", job.id, job.Name, string(job.params)))
-	if err := handler(context.Background(), job.params); err != nil {
+	if err := handler(ctx, job.params); err != nil {
 		next, ok := job.ConfigureNextAttempt(time.Now())
 		log.Error(fmt.Sprintf("failed to execute job handler (id=%s, name=%s, params=%#+v): %v", next.id, next.Name, string(next.params), err))
 		if !ok {
-			if _, err := s.db.Exec(`UPDATE jobs SET attempts = $1, failed = $2, locked_until = NULL WHERE id = $2`, next.attempts, now, next.id); err != nil {
+			if err := s.transition(now, next, StateFailed, err.Error()); err != nil {
 				log.Error(fmt.Sprintf("can't mark job as failed (id=%s, name=%s, params=%#+v): %v", next.id, next.Name, string(next.params), err))
 			}
 			return fmt.Errorf("handler failed with no remaining attempts")
 		}
 
-		if _, err := s.db.Exec(`UPDATE jobs SET attempts = $1, at = $2, locked_until = NULL WHERE id = $3`, next.attempts, next.At, next.id); err != nil {
+		if err := s.transition(now, next, StateScheduled, err.Error()); err != nil {
 			log.Error(fmt.Sprintf("can't reschedule next attempt (id=%s, name=%s, params=%#+v): %v", next.id, next.Name, string(next.params), err))
 		}
 
@@ -134,9 +245,103 @@ func (s *Server) executeJobHandler(now time.Time, handler HandlerFunc, job Job)
 	}
 
 	log.Info(fmt.Sprintf("job successfully processed (id=%s, name=%s, params=%#+v)", job.id, job.Name, string(job.params)))
-	if _, err := s.db.Exec(`DELETE FROM jobs WHERE id = $1`, job.id); err != nil {
-		log.Error(fmt.Sprintf("can't delete job after successful attempt (id=%s, name=%s, params=%#+v): %v", job.id, job.Name, string(job.params), err))
+	if s.SucceededJobsRetention == 0 {
+		if err := s.delete(job); err != nil {
+			log.Error(fmt.Sprintf("can't delete job after successful attempt (id=%s, name=%s, params=%#+v): %v", job.id, job.Name, string(job.params), err))
+		}
+
+		return nil
+	}
+
+	if err := s.transition(now, job, StateSucceeded, ""); err != nil {
+		log.Error(fmt.Sprintf("can't mark job as succeeded (id=%s, name=%s, params=%#+v): %v", job.id, job.Name, string(job.params), err))
+	}
+
+	return nil
+}
+
+// delete removes a succeeded job, unless it has been reclaimed by another worker.
+func (s *Server) delete(job Job) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = $1 AND state = $2 AND lock_id IS $3`, job.id, job.state, nullString(job.lockID))
+	if err != nil {
+		return fmt.Errorf("can't delete job (id=%s): %w: %v", job.id, ErrGeneric, err)
+	}
+
+	return nil
+}
+
+func (s *Server) purgeSucceededJobs(now time.Time) {
+	if s.SucceededJobsRetention == 0 {
+		return
+	}
+
+	filter := PurgeFilter{State: StateSucceeded, FinishedBefore: now.Add(-s.SucceededJobsRetention)}
+	if _, err := NewAdmin(s.db).Purge(filter); err != nil {
+		s.log.Error(fmt.Sprintf("can't purge succeeded jobs: %v", err))
+	}
+}
+
+// transition persists the job in its new state. It only succeeds if the job
+// is still in the state and held by the lock the worker knows about, so a job
+// reclaimed by another worker in the meantime is left untouched.
+func (s *Server) transition(now time.Time, job Job, to State, lastError string) error {
+	if err := validateTransition(job.id, job.state, to); err != nil {
+		return err
+	}
+
+	changes := newTransitionChanges(now, to, lastError)
+	result, err := s.db.Exec(`
+		UPDATE jobs
+		SET state = $1, attempts = $2, at = $3, locked_until = NULL, lock_id = NULL, finished_at = $4, last_error = $5,
+			progress = COALESCE($6, progress),
+			progress_message = CASE WHEN $7 THEN NULL ELSE progress_message END
+		WHERE id = $8 AND state = $9 AND lock_id IS $10`,
+		to, job.attempts, job.At, changes.finishedAt, changes.lastError, changes.progress, changes.resetProgressMessage, job.id, job.state, nullString(job.lockID))
+	if err != nil {
+		return fmt.Errorf("can't move job (id=%s) from %s to %s: %w: %v", job.id, job.state, to, ErrGeneric, err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can't count updated jobs (id=%s): %w: %v", job.id, ErrGeneric, err)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("can't move job (id=%s) from %s to %s: job state changed concurrently: %w", job.id, job.state, to, ErrInvalidTransition)
 	}
 
 	return nil
 }
+
+type transitionChanges struct {
+	finishedAt           interface{}
+	lastError            interface{}
+	progress             interface{}
+	resetProgressMessage bool
+}
+
+// newTransitionChanges computes the columns depending on the target state.
+// A nil value leaves the nullable column empty, or untouched for progress.
+func newTransitionChanges(now time.Time, to State, lastError string) transitionChanges {
+	var changes transitionChanges
+
+	if to.IsFinal() {
+		changes.finishedAt = now
+	}
+
+	if lastError != "" {
+		changes.lastError = lastError
+	}
+
+	// a new attempt starts from scratch while a success is always complete
+	switch to {
+	case StateScheduled:
+		changes.progress = 0
+		changes.resetProgressMessage = true
+	case StateSucceeded:
+		changes.progress = 100
+	case StateRunning, StateFailed, StateCancelled:
+	}
+
+	return changes
+}
@@ -0,0 +1,418 @@
+package job_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/logger"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/sqlutil"
+	"github.com/lonepeon/golib/testutils"
+)
+
+type transitionTestCase struct {
+	name        string
+	from        job.State
+	lockedUntil time.Duration
+	maxAttempts int
+	handler     job.HandlerFunc
+	action      func(*testing.T, *sql.DB, *job.Server, string) error
+	wantErr     error
+	wantState   job.State
+}
+
+func TestIntegrationServer(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	t.Parallel()
+
+	succeed := func(context.Context, []byte) error { return nil }
+	fail := func(context.Context, []byte) error { return errors.New("boom") }
+
+	processNextJob := func(t *testing.T, db *sql.DB, server *job.Server, id string) error {
+		testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+		return nil
+	}
+	skipNextJob := func(t *testing.T, db *sql.DB, server *job.Server, id string) error {
+		testutils.RequireEqualBool(t, false, server.ProcessNextJob(), "expected no job to be processed")
+		return nil
+	}
+	retry := func(t *testing.T, db *sql.DB, server *job.Server, id string) error {
+		return job.NewAdmin(db).Retry(id)
+	}
+	cancel := func(t *testing.T, db *sql.DB, server *job.Server, id string) error {
+		return job.NewAdmin(db).Cancel(id)
+	}
+
+	testCases := []transitionTestCase{
+		{name: "ScheduledToSucceeded", from: job.StateScheduled, handler: succeed, action: processNextJob, wantState: job.StateSucceeded},
+		{name: "ScheduledToRescheduled", from: job.StateScheduled, handler: fail, action: processNextJob, wantState: job.StateScheduled},
+		{name: "ScheduledToFailedOnLastAttempt", from: job.StateScheduled, maxAttempts: 1, handler: fail, action: processNextJob, wantState: job.StateFailed},
+		{name: "ScheduledToFailedWithoutHandler", from: job.StateScheduled, action: processNextJob, wantState: job.StateFailed},
+		{name: "ScheduledToCancelled", from: job.StateScheduled, action: cancel, wantState: job.StateCancelled},
+		{name: "ScheduledRetryIsRejected", from: job.StateScheduled, action: retry, wantErr: job.ErrInvalidTransition, wantState: job.StateScheduled},
+		{name: "RunningWithExpiredLockIsReclaimed", from: job.StateRunning, lockedUntil: -time.Second, handler: succeed, action: processNextJob, wantState: job.StateSucceeded},
+		{name: "RunningWithActiveLockIsSkipped", from: job.StateRunning, lockedUntil: time.Minute, handler: succeed, action: skipNextJob, wantState: job.StateRunning},
+		{name: "RunningCancelIsRejected", from: job.StateRunning, lockedUntil: time.Minute, action: cancel, wantErr: job.ErrJobLocked, wantState: job.StateRunning},
+		{name: "RunningRetryIsRejected", from: job.StateRunning, lockedUntil: time.Minute, action: retry, wantErr: job.ErrJobLocked, wantState: job.StateRunning},
+		{name: "SucceededRetryIsRejected", from: job.StateSucceeded, action: retry, wantErr: job.ErrInvalidTransition, wantState: job.StateSucceeded},
+		{name: "SucceededCancelIsRejected", from: job.StateSucceeded, action: cancel, wantErr: job.ErrInvalidTransition, wantState: job.StateSucceeded},
+		{name: "SucceededIsNotProcessed", from: job.StateSucceeded, handler: succeed, action: skipNextJob, wantState: job.StateSucceeded},
+		{name: "FailedToScheduled", from: job.StateFailed, action: retry, wantState: job.StateScheduled},
+		{name: "FailedCancelIsRejected", from: job.StateFailed, action: cancel, wantErr: job.ErrInvalidTransition, wantState: job.StateFailed},
+		{name: "FailedIsNotProcessed", from: job.StateFailed, handler: succeed, action: skipNextJob, wantState: job.StateFailed},
+		{name: "CancelledToScheduled", from: job.StateCancelled, action: retry, wantState: job.StateScheduled},
+		{name: "CancelledCancelIsRejected", from: job.StateCancelled, action: cancel, wantErr: job.ErrInvalidTransition, wantState: job.StateCancelled},
+		{name: "CancelledIsNotProcessed", from: job.StateCancelled, handler: succeed, action: skipNextJob, wantState: job.StateCancelled},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) { testTransition(t, tc) })
+	}
+
+	t.Run("HandlerRunsInRunningState", testHandlerRunsInRunningState)
+	t.Run("FailedAttemptIsRescheduled", testFailedAttemptIsRescheduled)
+	t.Run("FutureJobIsNotProcessed", testFutureJobIsNotProcessed)
+	t.Run("MigrationConvertsLegacyJobs", testMigrationConvertsLegacyJobs)
+	t.Run("ProgressIsReadableDuringExecution", testProgressIsReadableDuringExecution)
+	t.Run("ProgressIsResetWhenRescheduled", testProgressIsResetWhenRescheduled)
+	t.Run("ProgressOfUnknownJob", testProgressOfUnknownJob)
+	t.Run("TraceIDIsPropagatedToHandler", testTraceIDIsPropagatedToHandler)
+	t.Run("CheckHealth", testCheckHealth)
+	t.Run("StaleWorkerCantOverwriteReclaimedJob", testStaleWorkerCantOverwriteReclaimedJob)
+	t.Run("SucceededJobIsDeletedWithoutRetention", testSucceededJobIsDeletedWithoutRetention)
+	t.Run("SucceededJobIsPurgedAfterRetention", testSucceededJobIsPurgedAfterRetention)
+}
+
+func TestServerShutdownGivesUpWhenNotRunning(t *testing.T) {
+	log, _, closer := loggertest.NewFake(t)
+	t.Cleanup(closer)
+	server := job.NewServer(nil, job.NewRegistry(), log)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	testutils.AssertErrorIs(t, context.DeadlineExceeded, server.Shutdown(ctx), "unexpected shutdown error")
+}
+
+func testTransition(t *testing.T, tc transitionTestCase) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{"my-job": tc.handler})
+
+	j, err := job.NewJob("my-job", nil)
+	testutils.RequireNoError(t, err, "can't build job")
+	j.At = j.At.Add(-time.Minute)
+	if tc.maxAttempts != 0 {
+		j.MaxAttempts = tc.maxAttempts
+	}
+	testutils.RequireNoError(t, server.Client().Enqueue(j), "can't enqueue job")
+
+	var lockedUntil time.Time
+	if tc.lockedUntil != 0 {
+		lockedUntil = time.Now().Add(tc.lockedUntil)
+	}
+	setJobState(t, db, j.ID(), tc.from, lockedUntil)
+
+	err = tc.action(t, db, server, j.ID())
+	if tc.wantErr != nil {
+		testutils.AssertErrorIs(t, tc.wantErr, err, "unexpected action error")
+	} else {
+		testutils.AssertNoError(t, err, "unexpected action error")
+	}
+
+	details, err := job.NewAdmin(db).Lookup(j.ID())
+	testutils.RequireNoError(t, err, "can't lookup job")
+	testutils.AssertEqualString(t, string(tc.wantState), string(details.State), "unexpected job state")
+	testutils.AssertEqualBool(t, tc.wantState.IsFinal(), details.FinishedAt != nil, "unexpected finished_at presence")
+	if tc.wantState != job.StateRunning {
+		testutils.AssertEqualBool(t, true, details.LockedUntil == nil, "expected job to be unlocked: %v", details.LockedUntil)
+	}
+}
+
+func testHandlerRunsInRunningState(t *testing.T) {
+	db := setupDatabase(t)
+
+	var state job.State
+	var id string
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error {
+			details, err := job.NewAdmin(db).Lookup(id)
+			state = details.State
+			return err
+		},
+	})
+
+	id = enqueueJob(t, db, "my-job", nil).ID()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+	testutils.AssertEqualString(t, string(job.StateRunning), string(state), "unexpected state during execution")
+}
+
+func testFailedAttemptIsRescheduled(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error { return errors.New("boom") },
+	})
+
+	id := enqueueJob(t, db, "my-job", nil).ID()
+	before := time.Now()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+
+	details, err := job.NewAdmin(db).Lookup(id)
+	testutils.RequireNoError(t, err, "can't lookup job")
+	testutils.AssertEqualInt(t, 2, details.Attempts, "unexpected number of attempts")
+	testutils.AssertEqualString(t, "boom", details.LastError, "unexpected last error")
+	testutils.AssertEqualBool(t, true, details.At.After(before.Add(20*time.Second)), "expected job to be rescheduled later: %v", details.At)
+}
+
+func testFutureJobIsNotProcessed(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error { return nil },
+	})
+
+	j, err := job.NewJob("my-job", nil)
+	testutils.RequireNoError(t, err, "can't build job")
+	j.At = j.At.Add(time.Hour)
+	testutils.RequireNoError(t, server.Client().Enqueue(j), "can't enqueue job")
+
+	testutils.AssertEqualBool(t, false, server.ProcessNextJob(), "expected no job to be processed")
+}
+
+func testProgressIsReadableDuringExecution(t *testing.T) {
+	db := setupDatabase(t)
+
+	var id string
+	var during job.Progress
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(ctx context.Context, _ []byte) error {
+			if err := job.ReportProgress(ctx, 42, "importing rows"); err != nil {
+				return err
+			}
+
+			var err error
+			during, err = job.NewClient(db).Progress(id)
+			return err
+		},
+	})
+
+	id = enqueueJob(t, db, "my-job", nil).ID()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+
+	testutils.AssertEqualString(t, string(job.StateRunning), string(during.State), "unexpected state during execution")
+	testutils.AssertEqualInt(t, 42, during.Percent, "unexpected progress during execution")
+	testutils.AssertEqualString(t, "importing rows", during.Message, "unexpected progress message during execution")
+
+	after, err := job.NewClient(db).Progress(id)
+	testutils.RequireNoError(t, err, "can't get job progress")
+	testutils.AssertEqualString(t, string(job.StateSucceeded), string(after.State), "unexpected state after execution")
+	testutils.AssertEqualInt(t, 100, after.Percent, "unexpected progress after execution")
+}
+
+func testProgressIsResetWhenRescheduled(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(ctx context.Context, _ []byte) error {
+			if err := job.ReportProgress(ctx, 30, "importing rows"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	id := enqueueJob(t, db, "my-job", nil).ID()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+
+	progress, err := job.NewClient(db).Progress(id)
+	testutils.RequireNoError(t, err, "can't get job progress")
+	testutils.AssertEqualString(t, string(job.StateScheduled), string(progress.State), "unexpected state")
+	testutils.AssertEqualInt(t, 0, progress.Percent, "unexpected progress")
+	testutils.AssertEqualString(t, "", progress.Message, "unexpected progress message")
+}
+
+func testProgressOfUnknownJob(t *testing.T) {
+	db := setupDatabase(t)
+
+	_, err := job.NewClient(db).Progress("unknown")
+	testutils.AssertErrorIs(t, job.ErrJobNotFound, err, "unexpected error")
+}
+
+func testTraceIDIsPropagatedToHandler(t *testing.T) {
+	db := setupDatabase(t)
+
+	var traceID string
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(ctx context.Context, _ []byte) error {
+			traceID = logger.TraceIDFromContext(ctx)
+			return nil
+		},
+	})
+
+	j, err := job.NewJob("my-job", nil)
+	testutils.RequireNoError(t, err, "can't build job")
+	j.At = j.At.Add(-time.Minute)
+	ctx := logger.ContextWithTraceID(context.Background(), "my-trace-id")
+	testutils.RequireNoError(t, server.Client().EnqueueContext(ctx, j), "can't enqueue job")
+
+	details, err := job.NewAdmin(db).Lookup(j.ID())
+	testutils.RequireNoError(t, err, "can't lookup job")
+	testutils.AssertEqualString(t, "my-trace-id", details.TraceID, "unexpected stored trace id")
+
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+	testutils.AssertEqualString(t, "my-trace-id", traceID, "unexpected trace id in handler context")
+}
+
+func testStaleWorkerCantOverwriteReclaimedJob(t *testing.T) {
+	db := setupDatabase(t)
+
+	var id string
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error {
+			// another worker reclaims the job while this one is still running it
+			_, err := db.Exec(`UPDATE jobs SET lock_id = 'other-worker' WHERE id = $1`, id)
+			return err
+		},
+	})
+
+	id = enqueueJob(t, db, "my-job", nil).ID()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+
+	details, err := job.NewAdmin(db).Lookup(id)
+	testutils.RequireNoError(t, err, "can't lookup job")
+	testutils.AssertEqualString(t, string(job.StateRunning), string(details.State), "expected reclaimed job to be left untouched")
+}
+
+func testSucceededJobIsDeletedWithoutRetention(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error { return nil },
+	})
+	server.SucceededJobsRetention = 0
+
+	id := enqueueJob(t, db, "my-job", nil).ID()
+	testutils.RequireEqualBool(t, true, server.ProcessNextJob(), "expected a job to be processed")
+
+	_, err := job.NewAdmin(db).Lookup(id)
+	testutils.AssertErrorIs(t, job.ErrJobNotFound, err, "expected succeeded job to be deleted")
+}
+
+func testSucceededJobIsPurgedAfterRetention(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, map[string]job.HandlerFunc{
+		"my-job": func(context.Context, []byte) error { return nil },
+	})
+	server.SucceededJobsRetention = time.Millisecond
+	server.SleepDuration = time.Millisecond
+
+	id := enqueueJob(t, db, "my-job", nil).ID()
+
+	done := make(chan error, 1)
+	go func() { done <- server.ListenAndServe() }()
+
+	purged := false
+	for i := 0; i < 100 && !purged; i++ {
+		_, err := job.NewAdmin(db).Lookup(id)
+		purged = errors.Is(err, job.ErrJobNotFound)
+		time.Sleep(time.Millisecond)
+	}
+
+	testutils.RequireNoError(t, server.Shutdown(context.Background()), "can't shutdown server")
+	testutils.RequireNoError(t, <-done, "unexpected serve error")
+	testutils.AssertEqualBool(t, true, purged, "expected succeeded job to be purged")
+}
+
+func testCheckHealth(t *testing.T) {
+	db := setupDatabase(t)
+	server := setupServer(t, db, nil)
+	server.SleepDuration = time.Millisecond
+
+	testutils.AssertErrorIs(t, job.ErrServerNotRunning, server.CheckHealth(context.Background()), "expected stopped server to be unhealthy")
+
+	done := make(chan error, 1)
+	go func() { done <- server.ListenAndServe() }()
+
+	healthy := false
+	for i := 0; i < 100 && !healthy; i++ {
+		healthy = server.CheckHealth(context.Background()) == nil
+		time.Sleep(time.Millisecond)
+	}
+	testutils.AssertEqualBool(t, true, healthy, "expected running server to be healthy")
+
+	testutils.RequireNoError(t, server.Shutdown(context.Background()), "can't shutdown server")
+	testutils.RequireNoError(t, <-done, "unexpected serve error")
+	testutils.AssertErrorIs(t, job.ErrServerNotRunning, server.CheckHealth(context.Background()), "expected stopped server to be unhealthy")
+}
+
+func testMigrationConvertsLegacyJobs(t *testing.T) {
+	f, err := ioutil.TempFile("", "job-*.sqlite")
+	testutils.RequireNoError(t, err, "can't create SQLite temporary file")
+	db, err := sql.Open("sqlite3", f.Name())
+	testutils.RequireNoError(t, err, "can't open sqlite connection")
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(f.Name())
+	})
+
+	migrations := job.Migrations()
+	_, err = sqlutil.ExecuteMigrations(context.Background(), db, migrations[:1])
+	testutils.RequireNoError(t, err, "can't run legacy migrations")
+
+	now := time.Now()
+	legacyJobs := []struct {
+		id          string
+		attempts    int
+		lockedUntil interface{}
+		failed      interface{}
+	}{
+		{id: "scheduled", attempts: 1},
+		{id: "locked", attempts: 1, lockedUntil: now},
+		{id: "failed", attempts: 3, failed: now},
+		{id: "exhausted", attempts: 10},
+	}
+	for _, legacy := range legacyJobs {
+		_, err = db.Exec(`
+			INSERT INTO jobs (id, name, params, at, attempts, max_attempts, locked_until, failed)
+			VALUES (?, 'my-job', '{}', ?, ?, 10, ?, ?)`, legacy.id, now, legacy.attempts, legacy.lockedUntil, legacy.failed)
+		testutils.RequireNoError(t, err, "can't insert legacy job %s", legacy.id)
+	}
+
+	_, err = sqlutil.ExecuteMigrations(context.Background(), db, migrations)
+	testutils.RequireNoError(t, err, "can't run migrations")
+
+	admin := job.NewAdmin(db)
+	for id, want := range map[string]job.State{
+		"scheduled": job.StateScheduled,
+		"locked":    job.StateRunning,
+		"failed":    job.StateFailed,
+		"exhausted": job.StateFailed,
+	} {
+		details, err := admin.Lookup(id)
+		testutils.RequireNoError(t, err, "can't lookup job %s", id)
+		testutils.AssertEqualString(t, string(want), string(details.State), "unexpected state for job %s", id)
+	}
+}
+
+func setupServer(t *testing.T, db *sql.DB, handlers map[string]job.HandlerFunc) *job.Server {
+	log, _, closer := loggertest.NewFake(t)
+	t.Cleanup(closer)
+
+	registry := job.NewRegistry()
+	for name, handler := range handlers {
+		if handler != nil {
+			registry.RegisterFunc(name, handler)
+		}
+	}
+
+	server := job.NewServer(db, registry, log)
+	server.SucceededJobsRetention = time.Hour
+
+	return server
+}
@@ -0,0 +1,74 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid job state transition")
+)
+
+type State string
+
+const (
+	StateScheduled State = "scheduled"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// stateTransitions lists, for each state, the states a job can move to.
+// A running job can move to running again when a worker reclaims it after
+// the previous worker's lock expired.
+var stateTransitions = map[State][]State{
+	StateScheduled: {StateRunning, StateCancelled},
+	StateRunning:   {StateRunning, StateScheduled, StateSucceeded, StateFailed},
+	StateSucceeded: {},
+	StateFailed:    {StateScheduled},
+	StateCancelled: {StateScheduled},
+}
+
+func States() []State {
+	return []State{StateScheduled, StateRunning, StateSucceeded, StateFailed, StateCancelled}
+}
+
+func ParseState(value string) (State, error) {
+	for _, state := range States() {
+		if string(state) == value {
+			return state, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown job state (state=%s): %w", value, ErrGeneric)
+}
+
+func (s State) CanTransitionTo(next State) bool {
+	for _, state := range stateTransitions[s] {
+		if state == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s State) IsFinal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateCancelled:
+		return true
+	case StateScheduled, StateRunning:
+		return false
+	default:
+		return false
+	}
+}
+
+func validateTransition(id string, from State, to State) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("can't move job (id=%s) from %s to %s: %w", id, from, to, ErrInvalidTransition)
+	}
+
+	return nil
+}
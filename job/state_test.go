@@ -0,0 +1,42 @@
+package job_test
+
+import (
+	"testing"
+
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/testutils"
+)
+
+func TestStateCanTransitionTo(t *testing.T) {
+	allowed := map[job.State][]job.State{
+		job.StateScheduled: {job.StateRunning, job.StateCancelled},
+		job.StateRunning:   {job.StateRunning, job.StateScheduled, job.StateSucceeded, job.StateFailed},
+		job.StateSucceeded: {},
+		job.StateFailed:    {job.StateScheduled},
+		job.StateCancelled: {job.StateScheduled},
+	}
+
+	for _, from := range job.States() {
+		for _, to := range job.States() {
+			want := false
+			for _, state := range allowed[from] {
+				if state == to {
+					want = true
+				}
+			}
+
+			testutils.AssertEqualBool(t, want, from.CanTransitionTo(to), "unexpected transition from %s to %s", from, to)
+		}
+	}
+}
+
+func TestParseState(t *testing.T) {
+	for _, state := range job.States() {
+		parsed, err := job.ParseState(string(state))
+		testutils.RequireNoError(t, err, "can't parse state %s", state)
+		testutils.AssertEqualString(t, string(state), string(parsed), "unexpected parsed state")
+	}
+
+	_, err := job.ParseState("unknown")
+	testutils.AssertErrorIs(t, job.ErrGeneric, err, "expected unknown state to be rejected")
+}
@@ -0,0 +1,20 @@
+package logger
+
+import (
+	"context"
+)
+
+type traceIDKey struct{}
+
+// ContextWithTraceID returns a copy of ctx carrying traceID so work started
+// from it, such as background jobs, can be correlated with the original request.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID stored in ctx or an empty string.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+
+	return traceID
+}
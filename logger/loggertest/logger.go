@@ -52,3 +52,7 @@ func (w *Writer) Write(p []byte) (int, error) {
 
 	return n, nil
 }
+
+func (w *Writer) Lines() []Line {
+	return w.lines
+}
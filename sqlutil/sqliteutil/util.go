@@ -1,7 +1,9 @@
 package sqliteutil
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
@@ -14,3 +16,16 @@ func IsUniqueConstraintError(err error, index string) bool {
 
 	return strings.Contains(e.Error(), "UNIQUE ") && strings.Contains(e.Error(), index)
 }
+
+// ParseTime parses a time stored by the sqlite3 driver in a TEXT column.
+// The driver only converts them back to time.Time for DATETIME/TIMESTAMP columns.
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("can't parse sqlite time (value=%s)", value)
+}
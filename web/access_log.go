@@ -0,0 +1,161 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lonepeon/golib/logger"
+)
+
+const DefaultAccessLogSampleRate = 1
+
+// AccessLogOptions reduces the number of access log lines. Server errors are
+// always logged, whatever the sampling and the exclusions.
+type AccessLogOptions struct {
+	// SampleRate is the fraction of requests logged, up to 1. A rate of 0.1
+	// logs one request out of ten.
+	SampleRate float64
+	// OnlyServerErrors disables the logging of every request which didn't end
+	// with a server error, whatever the sample rate.
+	OnlyServerErrors bool
+	// ExcludedPaths lists the URL paths which are never logged, such as health
+	// checks. Paths ending with a slash exclude every path below them.
+	ExcludedPaths []string
+}
+
+type accessLog struct {
+	requests         uint64
+	sampleRate       float64
+	onlyServerErrors bool
+	excludedPaths    []string
+}
+
+func newAccessLog(opts AccessLogOptions) *accessLog {
+	return &accessLog{sampleRate: opts.SampleRate, onlyServerErrors: opts.OnlyServerErrors, excludedPaths: opts.ExcludedPaths}
+}
+
+func (l *accessLog) shouldLog(r *http.Request, code int) bool {
+	if code >= http.StatusInternalServerError {
+		return true
+	}
+
+	if l.onlyServerErrors {
+		return false
+	}
+
+	for _, path := range l.excludedPaths {
+		if r.URL.Path == path || (strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+			return false
+		}
+	}
+
+	if l.sampleRate >= 1 {
+		return true
+	}
+
+	n := atomic.AddUint64(&l.requests, 1)
+
+	return uint64(float64(n)*l.sampleRate) != uint64(float64(n-1)*l.sampleRate)
+}
+
+// logRequest writes the access log line of r once the response has been sent.
+func (s *Server) logRequest(reqLogger *logger.Logger, r *http.Request, w *responseWriter, msg string) {
+	if !s.accessLog.shouldLog(r, w.code) {
+		return
+	}
+
+	reqLogger = reqLogger.WithFields(
+		logger.Int("http.status_code", w.code),
+		logger.Int("http.response_content_length", int(w.written)),
+		logger.Float64("http.duration_ms", float64(time.Since(w.start))/float64(time.Millisecond)),
+	)
+
+	logFn := reqLogger.Info
+	if w.code >= http.StatusInternalServerError {
+		logFn = reqLogger.Error
+	}
+
+	logFn(msg)
+}
+
+// responseWriter records what has been sent to the client: the status code,
+// the number of body bytes and whether the headers are already gone, in which
+// case the response can't be replaced by an error page anymore.
+type responseWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	code        int
+	written     int64
+	headersSent bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, start: time.Now(), code: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.headersSent {
+		return
+	}
+
+	w.code = code
+	w.headersSent = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.headersSent {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.headersSent {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler when the original writer
+// supports it, as needed by WebSocket upgrades.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer can't be hijacked: %w", http.ErrNotSupported)
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.headersSent = true
+	}
+
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push when the original writer supports it.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return pusher.Push(target, opts)
+}
+
+// Unwrap gives access to the original writer, as expected by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,149 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestAccessLogFields(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, map[string]string{"name": "gopher"})
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	closer()
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualFloat64(t, 200, lines[0]["http.status_code"].(float64), "unexpected status code")
+	testutils.AssertEqualFloat64(t, float64(w.Body.Len()), lines[0]["http.response_content_length"].(float64), "unexpected content length")
+	duration, ok := lines[0]["http.duration_ms"].(float64)
+	testutils.AssertEqualBool(t, true, ok && duration >= 0, "expected a request duration, got %v", lines[0]["http.duration_ms"])
+}
+
+func TestAccessLogHandlerWritingDirectly(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("accepted"))
+		return ctx.InternalServerErrorResponse("too late to fail")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	closer()
+
+	testutils.AssertEqualInt(t, http.StatusAccepted, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "accepted", w.Body.String(), "unexpected body")
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualFloat64(t, 202, lines[0]["http.status_code"].(float64), "unexpected status code")
+	testutils.AssertEqualFloat64(t, 8, lines[0]["http.response_content_length"].(float64), "unexpected content length")
+	testutils.AssertEqualString(t, "too late to fail", lines[0]["msg"].(string), "unexpected log message")
+}
+
+func TestAccessLogExcludedPaths(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server, err := web.NewServerWithOptions(log, sessions.NewCookieStore([]byte("secret-key")), web.ServerOptions{
+		Templates: testTmplConfiguration(),
+		AccessLog: web.AccessLogOptions{ExcludedPaths: []string{"/healthz", "/internal/"}},
+	})
+	testutils.RequireNoError(t, err, "can't create server")
+
+	ok := func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, nil)
+	}
+	server.HandleFunc("GET", "/healthz", ok)
+	server.HandleFunc("GET", "/healthz/details", ok)
+	server.HandleFunc("GET", "/internal/metrics", ok)
+	server.HandleFunc("GET", "/internal/broken", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.InternalServerErrorResponse("broken")
+	})
+
+	for _, target := range []string{"/healthz", "/healthz/details", "/internal/metrics", "/internal/broken"} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	closer()
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 2, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualString(t, "/healthz/details", lines[0]["http.target"].(string), "unexpected logged target")
+	testutils.AssertEqualString(t, "/internal/broken", lines[1]["http.target"].(string), "expected server errors to be logged")
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server, err := web.NewServerWithOptions(log, sessions.NewCookieStore([]byte("secret-key")), web.ServerOptions{
+		Templates: testTmplConfiguration(),
+		AccessLog: web.AccessLogOptions{SampleRate: 0.25},
+	})
+	testutils.RequireNoError(t, err, "can't create server")
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, nil)
+	})
+
+	for i := 0; i < 8; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	closer()
+
+	testutils.AssertEqualInt(t, 2, len(logs.Lines()), "unexpected number of log lines")
+}
+
+func TestAccessLogOnlyServerErrors(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server, err := web.NewServerWithOptions(log, sessions.NewCookieStore([]byte("secret-key")), web.ServerOptions{
+		Templates: testTmplConfiguration(),
+		AccessLog: web.AccessLogOptions{OnlyServerErrors: true},
+	})
+	testutils.RequireNoError(t, err, "can't create server")
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, nil)
+	})
+	server.HandleFunc("GET", "/broken", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.InternalServerErrorResponse("broken")
+	})
+
+	for _, target := range []string{"/", "/broken", "/"} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	closer()
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualString(t, "/broken", lines[0]["http.target"].(string), "expected server errors to be logged")
+}
+
+func TestResponseWriterCanBeHijacked(t *testing.T) {
+	server := newTestServer(t, testTmplConfiguration())
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		testutils.RequireNoError(t, err, "can't hijack connection")
+		defer conn.Close()
+
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		testutils.AssertNoError(t, rw.Flush(), "can't flush hijacked connection")
+
+		return web.Response{}
+	})
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL)
+	testutils.RequireNoError(t, err, "can't send request")
+	defer resp.Body.Close()
+
+	testutils.AssertEqualString(t, "hijacked", readBody(t, resp), "unexpected body")
+}
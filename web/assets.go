@@ -0,0 +1,235 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	immutableCacheControl    = "public, max-age=31536000, immutable"
+	revalidateCacheControl   = "public, no-cache"
+	assetFingerprintLength   = 16
+	assetTemplateFuncName    = "asset"
+	assetPrecompressedGzip   = ".gz"
+	assetPrecompressedBrotli = ".br"
+)
+
+// precompressedEncodings lists the supported precompressed variants by order of preference.
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: assetPrecompressedBrotli},
+	{encoding: "gzip", extension: assetPrecompressedGzip},
+}
+
+type asset struct {
+	path        string
+	hash        string
+	fingerprint string
+	variants    map[string]string
+}
+
+type assetManifest struct {
+	prefix        string
+	fsys          fs.FS
+	byPath        map[string]*asset
+	byFingerprint map[string]*asset
+}
+
+// ServeAssets serves the files of fsys under the URL prefix. Each file is
+// available at its fingerprinted path, which embeds a hash of its content and
+// is cached forever by clients, and at its plain path which clients have to
+// revalidate. The "asset" template func returns the fingerprinted URL of a file.
+//
+// Files ending with .gz or .br are served instead of the file they compress
+// when the client accepts the matching encoding. They are served as regular
+// files when the file they would compress doesn't exist.
+func (s *Server) ServeAssets(prefix string, fsys fs.FS) error {
+	manifest, err := newAssetManifest(prefix, fsys)
+	if err != nil {
+		return err
+	}
+
+	s.assets = manifest
+	s.tmplFuncs[assetTemplateFuncName] = s.AssetPath
+	s.tmplCache.reset()
+
+	s.router.
+		PathPrefix(manifest.prefix).
+		Methods(http.MethodGet, http.MethodHead).
+		HandlerFunc(s.serveAsset)
+
+	return nil
+}
+
+// AssetPath returns the fingerprinted URL of the file name served by ServeAssets.
+func (s *Server) AssetPath(name string) (string, error) {
+	if s.assets == nil {
+		return "", fmt.Errorf("can't resolve asset %s: no assets served", name)
+	}
+
+	a, ok := s.assets.byPath[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", fmt.Errorf("can't resolve asset %s: file not found", name)
+	}
+
+	return s.assets.prefix + a.fingerprint, nil
+}
+
+func (s *Server) serveAsset(w http.ResponseWriter, r *http.Request) {
+	traceID := requestTraceID(r)
+	reqLogger := s.requestLogger(r, traceID)
+	w.Header().Add("Trace-ID", traceID)
+
+	rw := newResponseWriter(w)
+	msg := s.writeAsset(rw, r)
+	s.logRequest(reqLogger, r, rw, msg)
+}
+
+func (s *Server) writeAsset(w http.ResponseWriter, r *http.Request) string {
+	name := strings.TrimPrefix(r.URL.Path, s.assets.prefix)
+	cacheControl := immutableCacheControl
+	a, ok := s.assets.byFingerprint[name]
+	if !ok {
+		cacheControl = revalidateCacheControl
+		a, ok = s.assets.byPath[name]
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+		return "asset not found"
+	}
+
+	filename, encoding := a.path, ""
+	for _, e := range precompressedEncodings {
+		variant, ok := a.variants[e.encoding]
+		if ok && acceptsEncoding(r, e.encoding) {
+			filename, encoding = variant, e.encoding
+			break
+		}
+	}
+
+	content, err := fs.ReadFile(s.assets.fsys, filename)
+	if err != nil {
+		http.Error(w, "something wrong happened", http.StatusInternalServerError)
+		return fmt.Sprintf("can't read asset %s: %v", filename, err)
+	}
+
+	etag := a.hash
+	if encoding != "" {
+		etag += "-" + encoding
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if len(a.variants) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", `"`+etag+`"`)
+
+	http.ServeContent(w, r, a.path, time.Time{}, bytes.NewReader(content))
+
+	return "asset sent"
+}
+
+func newAssetManifest(prefix string, fsys fs.FS) (*assetManifest, error) {
+	manifest := assetManifest{
+		prefix:        "/" + strings.Trim(prefix, "/") + "/",
+		fsys:          fsys,
+		byPath:        make(map[string]*asset),
+		byFingerprint: make(map[string]*asset),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || isPrecompressedVariant(fsys, name) {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		hash := contentHash(content)
+		a := asset{path: name, hash: hash, fingerprint: fingerprint(name, hash), variants: make(map[string]string)}
+		for _, e := range precompressedEncodings {
+			if _, err := fs.Stat(fsys, name+e.extension); err == nil {
+				a.variants[e.encoding] = name + e.extension
+			}
+		}
+
+		manifest.byPath[a.path] = &a
+		manifest.byFingerprint[a.fingerprint] = &a
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't load assets: %v", err)
+	}
+
+	return &manifest, nil
+}
+
+// isPrecompressedVariant reports whether name compresses another file of fsys.
+// Compressed files without their uncompressed sibling, such as an archive, are
+// regular assets.
+func isPrecompressedVariant(fsys fs.FS, name string) bool {
+	for _, e := range precompressedEncodings {
+		if !strings.HasSuffix(name, e.extension) {
+			continue
+		}
+
+		if _, err := fs.Stat(fsys, strings.TrimSuffix(name, e.extension)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])[:assetFingerprintLength]
+}
+
+// fingerprint inserts hash before the extension of name: css/app.css becomes
+// css/app.0123456789abcdef.css.
+func fingerprint(name string, hash string) string {
+	ext := path.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(value, ";")
+		if strings.TrimSpace(params[0]) != encoding {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				quality, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				return err == nil && quality > 0
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
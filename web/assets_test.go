@@ -0,0 +1,146 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestServeAssetsFingerprintedPath(t *testing.T) {
+	server := setupAssetsServer(t)
+
+	assetPath, err := server.AssetPath("css/app.css")
+	testutils.RequireNoError(t, err, "can't resolve asset path")
+	testutils.AssertEqualBool(t, true, regexp.MustCompile(`^/assets/css/app\.[0-9a-f]{16}\.css$`).MatchString(assetPath), "unexpected asset path: %s", assetPath)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", assetPath, nil))
+
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "body{}", w.Body.String(), "unexpected body")
+	testutils.AssertEqualString(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"), "unexpected content type")
+	testutils.AssertEqualString(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"), "unexpected cache control")
+	testutils.AssertNotEmptyString(t, w.Header().Get("ETag"), "expected an etag")
+	testutils.AssertNotEmptyString(t, w.Header().Get("Trace-ID"), "expected a trace id")
+}
+
+func TestServeAssetsPlainPath(t *testing.T) {
+	server := setupAssetsServer(t)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/assets/js/app.js", nil))
+
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "alert(1)", w.Body.String(), "unexpected body")
+	testutils.AssertEqualString(t, "public, no-cache", w.Header().Get("Cache-Control"), "unexpected cache control")
+}
+
+func TestServeAssetsNotModified(t *testing.T) {
+	server := setupAssetsServer(t)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/assets/js/app.js", nil))
+	etag := w.Header().Get("ETag")
+
+	r := httptest.NewRequest("GET", "/assets/js/app.js", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+
+	testutils.AssertEqualInt(t, http.StatusNotModified, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "", w.Body.String(), "unexpected body")
+}
+
+func TestServeAssetsPrecompressedVariants(t *testing.T) {
+	tcs := map[string]struct {
+		acceptEncoding string
+		wantBody       string
+		wantEncoding   string
+		wantETagSuffix string
+	}{
+		"identity":      {acceptEncoding: "", wantBody: "body{}", wantEncoding: "", wantETagSuffix: `"`},
+		"gzip":          {acceptEncoding: "gzip", wantBody: "gzipped", wantEncoding: "gzip", wantETagSuffix: `-gzip"`},
+		"brotli":        {acceptEncoding: "gzip, deflate, br", wantBody: "brotlied", wantEncoding: "br", wantETagSuffix: `-br"`},
+		"brotliRefused": {acceptEncoding: "gzip, br;q=0", wantBody: "gzipped", wantEncoding: "gzip", wantETagSuffix: `-gzip"`},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			server := setupAssetsServer(t)
+
+			r := httptest.NewRequest("GET", "/assets/css/app.css", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+
+			testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+			testutils.AssertEqualString(t, tc.wantBody, w.Body.String(), "unexpected body")
+			testutils.AssertEqualString(t, tc.wantEncoding, w.Header().Get("Content-Encoding"), "unexpected content encoding")
+			testutils.AssertEqualString(t, "Accept-Encoding", w.Header().Get("Vary"), "unexpected vary header")
+			testutils.AssertEqualString(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"), "unexpected content type")
+			testutils.AssertEqualBool(t, true, regexp.MustCompile(tc.wantETagSuffix+"$").MatchString(w.Header().Get("ETag")), "unexpected etag: %s", w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestServeAssetsCompressedFileWithoutUncompressedSibling(t *testing.T) {
+	server := setupAssetsServer(t)
+
+	_, err := server.AssetPath("dump.tar.gz")
+	testutils.RequireNoError(t, err, "can't resolve asset path")
+
+	r := httptest.NewRequest("GET", "/assets/dump.tar.gz", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "archive", w.Body.String(), "unexpected body")
+	testutils.AssertEqualString(t, "", w.Header().Get("Content-Encoding"), "unexpected content encoding")
+}
+
+func TestServeAssetsNotFound(t *testing.T) {
+	server := setupAssetsServer(t)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/assets/css/unknown.css", nil))
+	testutils.AssertEqualInt(t, http.StatusNotFound, w.Code, "unexpected http code")
+
+	_, err := server.AssetPath("css/unknown.css")
+	testutils.AssertErrorContains(t, "file not found", err, "expected unknown asset to fail")
+}
+
+func TestServeAssetsTemplateFunc(t *testing.T) {
+	server := setupAssetsServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/assets.html", nil)
+	})
+
+	assetPath, err := server.AssetPath("css/app.css")
+	testutils.RequireNoError(t, err, "can't resolve asset path")
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.AssertContainsString(t, `<link rel="stylesheet" href="`+assetPath+`">`, body, "expected fingerprinted asset path")
+}
+
+func setupAssetsServer(t *testing.T) *web.Server {
+	server := setupServer(t)
+
+	err := server.ServeAssets("/assets", fstest.MapFS{
+		"css/app.css":    {Data: []byte("body{}")},
+		"css/app.css.gz": {Data: []byte("gzipped")},
+		"css/app.css.br": {Data: []byte("brotlied")},
+		"js/app.js":      {Data: []byte("alert(1)")},
+		"dump.tar.gz":    {Data: []byte("archive")},
+	})
+	testutils.RequireNoError(t, err, "can't serve assets")
+
+	return server
+}
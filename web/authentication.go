@@ -25,48 +25,50 @@ type AuthenticationUser struct {
 
 type CurrentAuthenticatedUserSessionStore struct {
 	store sessions.Store
+	name  string
 }
 
 func NewCurrentAuthenticatedUserSessionStore(store sessions.Store) CurrentAuthenticatedUserSessionStore {
 	return CurrentAuthenticatedUserSessionStore{
 		store: store,
+		name:  DefaultAuthCookieName,
 	}
 }
 
 func (c CurrentAuthenticatedUserSessionStore) Clear(w http.ResponseWriter, r *http.Request) error {
-	session, err := c.store.Get(r, "auth")
+	session, err := c.store.Get(r, c.name)
 	if err != nil {
-		return fmt.Errorf("can't get session 'auth': %v", err)
+		return fmt.Errorf("can't get session '%s': %v", c.name, err)
 	}
 
 	session.Values = nil
 
 	if err := session.Save(r, w); err != nil {
-		return fmt.Errorf("can't save cleared 'auth' session: %v", err)
+		return fmt.Errorf("can't save cleared '%s' session: %v", c.name, err)
 	}
 
 	return nil
 }
 
 func (c CurrentAuthenticatedUserSessionStore) StoreUserID(w http.ResponseWriter, r *http.Request, id AuthenticationUserID) error {
-	session, err := c.store.Get(r, "auth")
+	session, err := c.store.Get(r, c.name)
 	if err != nil {
-		return fmt.Errorf("can't get session 'auth': %v", err)
+		return fmt.Errorf("can't get session '%s': %v", c.name, err)
 	}
 
 	session.Values["auth_id"] = id.String()
 
 	if err := session.Save(r, w); err != nil {
-		return fmt.Errorf("can't save username to session 'auth': %v", err)
+		return fmt.Errorf("can't save username to session '%s': %v", c.name, err)
 	}
 
 	return nil
 }
 
 func (c CurrentAuthenticatedUserSessionStore) CurrentUserID(r *http.Request) (AuthenticationUserID, error) {
-	session, err := c.store.Get(r, "auth")
+	session, err := c.store.Get(r, c.name)
 	if err != nil {
-		return "", fmt.Errorf("can't get session 'auth': %v", err)
+		return "", fmt.Errorf("can't get session '%s': %v", c.name, err)
 	}
 
 	id, ok := session.Values["auth_id"].(string)
@@ -136,7 +138,7 @@ func (a Authentication) ShowLoginPage(alreadyLoggedinRedirectPath string) Handle
 func (a Authentication) Login(successfulLoginRedirectPath string) HandlerFunc {
 	return func(ctx Context, w http.ResponseWriter, r *http.Request) Response {
 		if err := r.ParseForm(); err != nil {
-			ctx.AddFlash(NewFlashMessageError("can't parse request parameters. Please try again."))
+			ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.invalid_request"))
 			response := ctx.Response(http.StatusOK, a.loginTemplatePath, nil)
 			response.LogMessage = fmt.Sprintf("can't parse form: %v", err)
 			return response
@@ -146,24 +148,24 @@ func (a Authentication) Login(successfulLoginRedirectPath string) HandlerFunc {
 		password := r.FormValue("password")
 
 		if username == "" || password == "" {
-			ctx.AddFlash(NewFlashMessageError("username/password combination is required"))
+			ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.missing_credentials"))
 			return ctx.Response(http.StatusOK, a.loginTemplatePath, nil)
 		}
 
 		user, err := a.authenticateUser(username, password)
 		if err != nil {
 			if errors.Is(err, ErrUserInvalidCredentials) {
-				ctx.AddFlash(NewFlashMessageError("username/password combination is invalid"))
+				ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.invalid_credentials"))
 				return ctx.Response(http.StatusOK, a.loginTemplatePath, nil)
 			}
-			ctx.AddFlash(NewFlashMessageError("something wrong happened. Please try again."))
+			ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.internal_error"))
 			response := ctx.Response(http.StatusOK, a.loginTemplatePath, nil)
 			response.LogMessage = fmt.Sprintf("can't authenticate user because of storage error: %v", err)
 			return response
 		}
 
 		if err := a.frontendStorage.StoreUserID(w, r, user.ID); err != nil {
-			ctx.AddFlash(NewFlashMessageError("something wrong happened. Please try again."))
+			ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.internal_error"))
 			response := ctx.Response(http.StatusOK, a.loginTemplatePath, nil)
 			response.LogMessage = fmt.Sprintf("can't login username because of storage error: %v", err)
 			return response
@@ -180,7 +182,7 @@ func (a Authentication) Logout(successfulLogoutRedirectPath string) HandlerFunc
 
 		logMsg := fmt.Sprintf("redirecting to %v", dest)
 		if err := a.frontendStorage.Clear(w, r); err != nil {
-			ctx.AddFlash(NewFlashMessageError("We can't log you out. Please retry"))
+			ctx.AddFlash(NewTranslatedFlashMessageError("web.authentication.logout_failed"))
 			logMsg = fmt.Sprintf("%s: can't remove user from storage: %v", logMsg, err)
 		}
 
@@ -91,7 +91,7 @@ func TestLogoutRedictWhenStorageFails(t *testing.T) {
 
 	frontend.EXPECT().Clear(w, r).Return(errors.New("boom"))
 	expectedResponse := webtest.MockedResponse("expected response")
-	ctx.EXPECT().AddFlash(web.NewFlashMessageError("We can't log you out. Please retry"))
+	ctx.EXPECT().AddFlash(web.NewTranslatedFlashMessageError("web.authentication.logout_failed"))
 	ctx.EXPECT().Redirect(w, 302, "/login").Return(expectedResponse)
 
 	response := auth.Logout("/login")(ctx, w, r)
@@ -146,7 +146,7 @@ func TestLoginWhenNoUsername(t *testing.T) {
 	auth := web.NewAuthentication(nil, nil, "login/new.html")
 
 	expectedResponse := webtest.MockedResponse("expected response")
-	ctx.EXPECT().AddFlash(web.NewFlashMessageError("username/password combination is required"))
+	ctx.EXPECT().AddFlash(webtest.MatchFlashErrorContains("username/password combination is required"))
 	ctx.EXPECT().Response(200, "login/new.html", nil).Return(expectedResponse)
 
 	response := auth.Login("/dashboard")(ctx, w, r)
@@ -166,7 +166,7 @@ func TestLoginWhenInvalidCredentials(t *testing.T) {
 
 	backend.EXPECT().Authenticate("jane", "doe").Return(web.AuthenticationUserID(""), web.ErrUserInvalidCredentials)
 	expectedResponse := webtest.MockedResponse("expected response")
-	ctx.EXPECT().AddFlash(web.NewFlashMessageError("username/password combination is invalid"))
+	ctx.EXPECT().AddFlash(webtest.MatchFlashErrorContains("web.authentication.invalid_credentials"))
 	ctx.EXPECT().Response(200, "login/new.html", nil).Return(expectedResponse)
 
 	response := auth.Login("/dashboard")(ctx, w, r)
@@ -189,7 +189,7 @@ func TestLoginWithValidCredentialsButCantPersistUsername(t *testing.T) {
 	backend.EXPECT().Lookup(web.AuthenticationUserID("42")).Return(web.AuthenticationUser{ID: web.AuthenticationUserID("42"), Username: "jdoe"}, nil)
 	frontend.EXPECT().StoreUserID(w, r, web.AuthenticationUserID("42")).Return(errors.New("boom"))
 	expectedResponse := webtest.MockedResponse("expected response")
-	ctx.EXPECT().AddFlash(web.NewFlashMessageError("something wrong happened. Please try again."))
+	ctx.EXPECT().AddFlash(web.NewTranslatedFlashMessageError("web.authentication.internal_error"))
 	ctx.EXPECT().Response(200, "login/new.html", nil).Return(expectedResponse)
 
 	response := auth.Login("/dashboard")(ctx, w, r)
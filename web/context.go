@@ -7,17 +7,31 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/logger"
 )
 
 type Context interface {
 	StdCtx() context.Context
 	AddData(string, interface{})
 	AddFlash(f FlashMessage)
+	PeekFlashes(key string) []FlashMessage
+	ConsumeFlashes(key string) []FlashMessage
 	Response(httpCode int, template string, data map[string]interface{}) Response
 	Redirect(w http.ResponseWriter, httpCode int, target string) Response
 	NotFoundResponse(format string, vars ...interface{}) Response
 	InternalServerErrorResponse(format string, vars ...interface{}) Response
+	JSONResponse(httpCode int, data interface{}) Response
+	JSONErrorResponse(httpCode int, format string, vars ...interface{}) Response
+	NegotiatedResponse(r *http.Request, httpCode int, template string, data map[string]interface{}) Response
+	EventStreamResponse(fn EventStreamFunc) Response
 	Vars(r *http.Request) map[string]string
+	BindForm(r *http.Request, dst interface{}) error
+	ReceiveUpload(r *http.Request, field string, storage UploadStorer, opts UploadOptions) (Upload, error)
+	Logger() *logger.Logger
+	TraceID() string
+	Locale() string
+	SetLocale(locale string)
+	T(key string, params ...interface{}) string
 }
 
 type ContextImpl struct {
@@ -26,14 +40,68 @@ type ContextImpl struct {
 	data              map[string]interface{}
 	session           *sessions.Session
 	tmplConfiguration TmplConfiguration
+	csrfToken         string
+	form              Form
+	logger            *logger.Logger
+	traceID           string
+	catalog           *Catalog
+	locale            string
 }
 
 func (c *ContextImpl) StdCtx() context.Context {
 	return c
 }
 
+// Logger returns the request logger, which already carries the trace ID and
+// the http.* fields of the request.
+func (c *ContextImpl) Logger() *logger.Logger {
+	return c.logger
+}
+
+// TraceID returns the ID identifying the request in logs. It's also stored in
+// StdCtx so jobs enqueued with job.Client.EnqueueContext are correlated with the request.
+func (c *ContextImpl) TraceID() string {
+	return c.traceID
+}
+
+// Locale returns the locale used to render the response, negotiated from the
+// locale cookie and the Accept-Language header unless SetLocale was called.
+func (c *ContextImpl) Locale() string {
+	return c.locale
+}
+
+// SetLocale overrides the negotiated locale, for instance with the preference
+// of the authenticated user. Unsupported locales fall back to the default one.
+func (c *ContextImpl) SetLocale(locale string) {
+	catalog := c.catalog
+	if catalog == nil {
+		catalog = builtinCatalog
+	}
+
+	c.locale = catalog.resolve(locale)
+}
+
+// T translates key in the request locale. params are name/value pairs used by
+// the message.
+func (c *ContextImpl) T(key string, params ...interface{}) string {
+	return translate(c.catalog, c.locale, key, translationParams(params))
+}
+
+// AddFlash stores f in the session until a page consumes it, scoped by f.Key.
 func (c *ContextImpl) AddFlash(f FlashMessage) {
-	c.session.AddFlash(&f)
+	c.session.AddFlash(&f, flashSessionKey(f.Key))
+}
+
+// PeekFlashes returns the flashes scoped by key, translated in the request
+// locale, leaving them in the session for the next page.
+func (c *ContextImpl) PeekFlashes(key string) []FlashMessage {
+	return flashMessages(c.catalog, c.locale, peekFlashes(c.session, key))
+}
+
+// ConsumeFlashes returns the flashes scoped by key, translated in the request
+// locale, and removes them from the session.
+func (c *ContextImpl) ConsumeFlashes(key string) []FlashMessage {
+	return flashMessages(c.catalog, c.locale, consumeFlashes(c.session, key))
 }
 
 func (c *ContextImpl) AddData(key string, data interface{}) {
@@ -93,6 +161,71 @@ func (c *ContextImpl) InternalServerErrorResponse(format string, vars ...interfa
 	}
 }
 
+func (c *ContextImpl) JSONResponse(httpCode int, data interface{}) Response {
+	return Response{
+		HTTPCode:   httpCode,
+		Format:     ResponseFormatJSON,
+		LogMessage: "response sent",
+		Data:       data,
+	}
+}
+
+// JSONErrorResponse wraps the formatted message in a JSONError envelope. The
+// message is sent to the client so it must not leak internal details.
+func (c *ContextImpl) JSONErrorResponse(httpCode int, format string, vars ...interface{}) Response {
+	msg := fmt.Sprintf(format, vars...)
+
+	return Response{
+		HTTPCode:   httpCode,
+		Format:     ResponseFormatJSON,
+		LogMessage: msg,
+		Data:       JSONError{Error: JSONErrorDetails{Status: httpCode, Message: msg}},
+	}
+}
+
+// NegotiatedResponse renders template as HTML unless the request Accept header
+// prefers JSON, in which case only data is encoded, without the values
+// registered with AddData. The response varies on Accept so caches keep both.
+func (c *ContextImpl) NegotiatedResponse(r *http.Request, httpCode int, template string, data map[string]interface{}) Response {
+	if negotiateFormat(r.Header.Get("Accept")) == ResponseFormatJSON {
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		return c.JSONResponse(httpCode, data).WithHeader("Vary", "Accept")
+	}
+
+	return c.Response(httpCode, template, data).WithHeader("Vary", "Accept")
+}
+
+// EventStreamResponse streams server-sent events produced by fn. Flashes are
+// left in the session for the next HTML page. Routes serving event streams
+// usually need Route.WriteTimeout to outlive the server write timeout.
+func (c *ContextImpl) EventStreamResponse(fn EventStreamFunc) Response {
+	return Response{
+		HTTPCode:    http.StatusOK,
+		Format:      ResponseFormatEventStream,
+		LogMessage:  "event stream closed",
+		eventStream: fn,
+	}
+}
+
 func (c *ContextImpl) Vars(r *http.Request) map[string]string {
 	return mux.Vars(r)
 }
+
+// BindForm fills dst from the request form, query string and route variables
+// and validates it. It returns FormErrors when some values are invalid. The
+// submitted values and their errors are exposed to templates as .Form.
+func (c *ContextImpl) BindForm(r *http.Request, dst interface{}) error {
+	form, err := bindForm(r, dst)
+	c.form = form
+
+	return err
+}
+
+// ReceiveUpload checks the file sent as field against opts and streams it to
+// storage. It returns ErrUploadMissing, ErrUploadTooLarge or
+// ErrUploadInvalidContentType when the file can't be accepted.
+func (c *ContextImpl) ReceiveUpload(r *http.Request, field string, storage UploadStorer, opts UploadOptions) (Upload, error) {
+	return receiveUpload(r, field, storage, opts)
+}
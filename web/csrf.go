@@ -0,0 +1,103 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// CSRFFieldName is the form field checked for the CSRF token when the
+	// header is missing. Reading it parses the whole form, uploads included.
+	CSRFFieldName = "csrf_token"
+	// CSRFHeaderName is the header checked first for the CSRF token, which
+	// leaves the body untouched so uploads can be streamed.
+	CSRFHeaderName = "X-CSRF-Token"
+
+	csrfSessionKey = "csrf-token"
+)
+
+// EnableCSRFProtection makes the server store a CSRF token in the session,
+// expose it to templates as .CSRFToken and reject requests using unsafe methods
+// which don't send it back. TmplConfiguration.CSRFFailureTemplate is rendered
+// with the error layout when the validation fails, or a plain 403 Forbidden
+// when it's empty.
+func (s *Server) EnableCSRFProtection() {
+	s.csrfEnabled = true
+}
+
+// ExemptFromCSRF disables the CSRF validation on all the routes of the group,
+// for instance JSON APIs authenticated by other means. Route.ExemptFromCSRF
+// exempts a single route.
+func (g *Group) ExemptFromCSRF() {
+	g.csrfExempt = true
+}
+
+func (g *Group) isCSRFExempt() bool {
+	if g == nil || g.csrfExempt {
+		return true
+	}
+
+	return g.parent != nil && g.parent.isCSRFExempt()
+}
+
+// ExemptFromCSRF disables the CSRF validation of the route, for instance a
+// webhook authenticated by a signature.
+func (r *Route) ExemptFromCSRF() *Route {
+	r.csrfExempt = true
+
+	return r
+}
+
+func (r *Route) isCSRFExempt() bool {
+	return r != nil && r.csrfExempt
+}
+
+func csrfToken(session *sessions.Session) (string, error) {
+	if token, ok := session.Values[csrfSessionKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate csrf token: %v", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	session.Values[csrfSessionKey] = token
+
+	return token, nil
+}
+
+func isCSRFSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func validCSRFToken(r *http.Request, token string) bool {
+	submitted := r.Header.Get(CSRFHeaderName)
+	if submitted == "" {
+		submitted = r.PostFormValue(CSRFFieldName)
+	}
+
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+// csrfFailureResponse answers a plain 403 Forbidden to HTML clients when no
+// CSRF failure template is configured.
+func (s *Server) csrfFailureResponse(ctx *ContextImpl, w http.ResponseWriter, r *http.Request) Response {
+	if s.tmplCfg.CSRFFailureTemplate == "" && negotiateFormat(r.Header.Get("Accept")) != ResponseFormatJSON {
+		http.Error(w, "invalid csrf token", http.StatusForbidden)
+		return Response{HTTPCode: http.StatusForbidden, LogMessage: "invalid csrf token"}
+	}
+
+	return s.errorResponse(ctx, r, http.StatusForbidden, s.tmplCfg.CSRFFailureTemplate, "invalid csrf token")
+}
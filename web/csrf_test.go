@@ -0,0 +1,165 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	tcs := map[string]struct {
+		exempt   bool
+		token    func(token string) (form url.Values, header string)
+		accept   string
+		wantCode int
+		wantBody string
+	}{
+		"missingToken": {
+			token:    func(string) (url.Values, string) { return nil, "" },
+			wantCode: http.StatusForbidden,
+			wantBody: "invalid csrf token",
+		},
+		"invalidToken": {
+			token:    func(string) (url.Values, string) { return url.Values{web.CSRFFieldName: {"forged"}}, "" },
+			wantCode: http.StatusForbidden,
+			wantBody: "invalid csrf token",
+		},
+		"invalidTokenAsJSON": {
+			token:    func(string) (url.Values, string) { return nil, "forged" },
+			accept:   "application/json",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"error":{"status":403,"message":"invalid csrf token"}}`,
+		},
+		"validFormToken": {
+			token:    func(token string) (url.Values, string) { return url.Values{web.CSRFFieldName: {token}}, "" },
+			wantCode: http.StatusOK,
+			wantBody: "saved",
+		},
+		"validHeaderToken": {
+			token:    func(token string) (url.Values, string) { return nil, token },
+			wantCode: http.StatusOK,
+			wantBody: "saved",
+		},
+		"exemptRoute": {
+			exempt:   true,
+			token:    func(string) (url.Values, string) { return nil, "" },
+			wantCode: http.StatusOK,
+			wantBody: "saved",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			server := setupServer(t)
+			server.EnableCSRFProtection()
+
+			routes := server.Group("")
+			if tc.exempt {
+				routes.ExemptFromCSRF()
+			}
+
+			server.HandleFunc("GET", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				return ctx.Response(http.StatusOK, "testdata/templates/form.html", nil)
+			})
+			routes.HandleFunc("POST", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				return ctx.JSONResponse(http.StatusOK, "saved")
+			})
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+			token := extractCSRFToken(t, w.Body.String())
+
+			form, header := tc.token(token)
+			r := httptest.NewRequest("POST", "/form", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Accept", tc.accept)
+			if header != "" {
+				r.Header.Set(web.CSRFHeaderName, header)
+			}
+			for _, cookie := range w.Result().Cookies() {
+				r.AddCookie(cookie)
+			}
+
+			body := serve(t, server, r, tc.wantCode)
+
+			testutils.AssertContainsString(t, tc.wantBody, body, "unexpected body")
+		})
+	}
+}
+
+func TestCSRFTokenIsStableAcrossRequests(t *testing.T) {
+	server := setupServer(t)
+	server.EnableCSRFProtection()
+	server.HandleFunc("GET", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/form.html", nil)
+	})
+
+	first := httptest.NewRecorder()
+	server.ServeHTTP(first, httptest.NewRequest("GET", "/form", nil))
+
+	r := httptest.NewRequest("GET", "/form", nil)
+	for _, cookie := range first.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	second := httptest.NewRecorder()
+	server.ServeHTTP(second, r)
+
+	testutils.AssertEqualString(t, extractCSRFToken(t, first.Body.String()), extractCSRFToken(t, second.Body.String()), "expected token to be kept in session")
+}
+
+func TestCSRFExemptRoute(t *testing.T) {
+	server := setupServer(t)
+	server.EnableCSRFProtection()
+	server.HandleFunc("POST", "/webhook", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "received")
+	}).ExemptFromCSRF()
+	server.HandleFunc("POST", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "saved")
+	})
+
+	serve(t, server, httptest.NewRequest("POST", "/webhook", nil), http.StatusOK)
+	serve(t, server, httptest.NewRequest("POST", "/form", nil), http.StatusForbidden)
+}
+
+func TestCSRFProtectionWithoutFailureTemplate(t *testing.T) {
+	tmplCfg := testTmplConfiguration()
+	tmplCfg.CSRFFailureTemplate = ""
+	server := newTestServer(t, tmplCfg)
+	server.EnableCSRFProtection()
+	server.HandleFunc("POST", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "saved")
+	})
+
+	body := serve(t, server, httptest.NewRequest("POST", "/form", nil), http.StatusForbidden)
+
+	testutils.AssertEqualString(t, "invalid csrf token\n", body, "unexpected body")
+}
+
+func TestCSRFProtectionDisabled(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("POST", "/form", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "saved")
+	})
+
+	serve(t, server, httptest.NewRequest("POST", "/form", nil), http.StatusOK)
+}
+
+var csrfInputRegexp = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfInputRegexp.FindStringSubmatch(body)
+	if len(matches) != 2 {
+		t.Fatalf("can't find csrf token in body:\n%s", body)
+	}
+
+	return matches[1]
+}
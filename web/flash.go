@@ -3,21 +3,146 @@ package web
 import (
 	"encoding/gob"
 	"fmt"
+
+	"github.com/gorilla/sessions"
 )
 
 func init() {
 	gob.Register(FlashMessage{})
 }
 
+type FlashKind string
+
+const (
+	FlashKindError   FlashKind = "error"
+	FlashKindWarning FlashKind = "warning"
+	FlashKindInfo    FlashKind = "info"
+	FlashKindSuccess FlashKind = "success"
+)
+
 type FlashMessage struct {
-	Kind    string
+	Kind    FlashKind
 	Message string
+	Title   string
+	// Field is the name of the form field the message is about, if any.
+	Field       string
+	Dismissible bool
+	// Key scopes the flash so it's only consumed by the responses asking for
+	// it. Flashes without key are consumed by every page.
+	Key string
+
+	// TranslationKey and TranslationParams are used to fill Message in the
+	// locale of the request rendering the flash. Until then, Message holds the
+	// built-in message of the default locale, or the key itself.
+	TranslationKey    string
+	TranslationParams map[string]string
+}
+
+func NewFlashMessage(kind FlashKind, pattern string, vars ...interface{}) FlashMessage {
+	return FlashMessage{Kind: kind, Message: fmt.Sprintf(pattern, vars...)}
 }
 
 func NewFlashMessageError(pattern string, vars ...interface{}) FlashMessage {
-	return FlashMessage{Kind: "error", Message: fmt.Sprintf(pattern, vars...)}
+	return NewFlashMessage(FlashKindError, pattern, vars...)
+}
+
+func NewFlashMessageWarning(pattern string, vars ...interface{}) FlashMessage {
+	return NewFlashMessage(FlashKindWarning, pattern, vars...)
+}
+
+func NewFlashMessageInfo(pattern string, vars ...interface{}) FlashMessage {
+	return NewFlashMessage(FlashKindInfo, pattern, vars...)
 }
 
 func NewFlashMessageSuccess(pattern string, vars ...interface{}) FlashMessage {
-	return FlashMessage{Kind: "success", Message: fmt.Sprintf(pattern, vars...)}
+	return NewFlashMessage(FlashKindSuccess, pattern, vars...)
+}
+
+// NewTranslatedFlashMessage creates a flash translated when rendered. params
+// are name/value pairs used by the message.
+func NewTranslatedFlashMessage(kind FlashKind, key string, params ...interface{}) FlashMessage {
+	f := FlashMessage{Kind: kind, TranslationKey: key, TranslationParams: translationParams(params)}
+
+	return f.translate(nil, builtinCatalog.defaultLocale)
+}
+
+func NewTranslatedFlashMessageError(key string, params ...interface{}) FlashMessage {
+	return NewTranslatedFlashMessage(FlashKindError, key, params...)
+}
+
+func NewTranslatedFlashMessageWarning(key string, params ...interface{}) FlashMessage {
+	return NewTranslatedFlashMessage(FlashKindWarning, key, params...)
+}
+
+func NewTranslatedFlashMessageInfo(key string, params ...interface{}) FlashMessage {
+	return NewTranslatedFlashMessage(FlashKindInfo, key, params...)
+}
+
+func NewTranslatedFlashMessageSuccess(key string, params ...interface{}) FlashMessage {
+	return NewTranslatedFlashMessage(FlashKindSuccess, key, params...)
+}
+
+func (f FlashMessage) WithTitle(title string) FlashMessage {
+	f.Title = title
+	return f
+}
+
+func (f FlashMessage) WithField(field string) FlashMessage {
+	f.Field = field
+	return f
+}
+
+func (f FlashMessage) WithKey(key string) FlashMessage {
+	f.Key = key
+	return f
+}
+
+func (f FlashMessage) AsDismissible() FlashMessage {
+	f.Dismissible = true
+	return f
+}
+
+func (f FlashMessage) translate(c *Catalog, locale string) FlashMessage {
+	if f.TranslationKey != "" {
+		f.Message = translate(c, locale, f.TranslationKey, f.TranslationParams)
+	}
+
+	return f
+}
+
+// flashSessionKey returns the session key holding the flashes scoped by key.
+// Flashes without key use the default key of gorilla/sessions.
+func flashSessionKey(key string) string {
+	if key == "" {
+		return "_flash"
+	}
+
+	return "_flash:" + key
+}
+
+// peekFlashes returns the flashes stored under key without removing them from
+// the session.
+func peekFlashes(session *sessions.Session, key string) []interface{} {
+	flashes, _ := session.Values[flashSessionKey(key)].([]interface{})
+
+	return flashes
+}
+
+func consumeFlashes(session *sessions.Session, key string) []interface{} {
+	return session.Flashes(flashSessionKey(key))
+}
+
+// flashMessages converts the flashes read from the session and translates them.
+func flashMessages(c *Catalog, locale string, flashes []interface{}) []FlashMessage {
+	messages := make([]FlashMessage, 0, len(flashes))
+	for _, flash := range translateFlashes(c, locale, append([]interface{}(nil), flashes...)) {
+		switch f := flash.(type) {
+		case *FlashMessage:
+			messages = append(messages, *f)
+		case FlashMessage:
+			messages = append(messages, f)
+		}
+	}
+
+	return messages
 }
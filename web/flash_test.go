@@ -0,0 +1,98 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestFlashesAreScopedByKey(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("POST", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewFlashMessageWarning("email is already taken").WithField("email"))
+		ctx.AddFlash(web.NewFlashMessageInfo("3 new messages").WithKey("sidebar").WithTitle("Inbox").AsDismissible())
+		return ctx.Redirect(w, http.StatusFound, "/")
+	})
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/flashes.html", nil)
+	})
+	server.HandleFunc("GET", "/sidebar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/flashes.html", nil).WithFlashes("sidebar")
+	})
+
+	cookies := serveWithCookies(t, server, httptest.NewRequest("POST", "/", nil), nil)
+
+	page := httptest.NewRecorder()
+	server.ServeHTTP(page, withCookies(httptest.NewRequest("GET", "/", nil), cookies))
+	testutils.AssertContainsString(t, `<p class="flash-warning">email is already taken</p>`, page.Body.String(), "expected unscoped flash")
+	testutils.AssertContainsString(t, `<span class="field-error">email is already taken</span>`, page.Body.String(), "expected field flash")
+	testutils.AssertEqualBool(t, false, strings.Contains(page.Body.String(), "aside"), "expected scoped flash not to be consumed:\n%s", page.Body.String())
+	cookies = mergeCookies(cookies, page.Result().Cookies())
+
+	sidebar := httptest.NewRecorder()
+	server.ServeHTTP(sidebar, withCookies(httptest.NewRequest("GET", "/sidebar", nil), cookies))
+	testutils.AssertContainsString(t, `<aside class="flash-info">Inbox: 3 new messages [x]</aside>`, sidebar.Body.String(), "expected scoped flash")
+	cookies = mergeCookies(cookies, sidebar.Result().Cookies())
+
+	again := httptest.NewRecorder()
+	server.ServeHTTP(again, withCookies(httptest.NewRequest("GET", "/sidebar", nil), cookies))
+	testutils.AssertEqualBool(t, false, strings.Contains(again.Body.String(), "flash"), "expected flashes to be consumed:\n%s", again.Body.String())
+}
+
+func TestContextPeekAndConsumeFlashes(t *testing.T) {
+	var peeked, consumed, remaining []web.FlashMessage
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewFlashMessageSuccess("saved"))
+		ctx.AddFlash(web.NewFlashMessageError("quota exceeded").WithKey("quota"))
+
+		peeked = ctx.PeekFlashes("")
+		consumed = ctx.ConsumeFlashes("quota")
+		remaining = ctx.PeekFlashes("quota")
+
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.RequireEqualInt(t, 1, len(peeked), "unexpected number of peeked flashes")
+	testutils.AssertEqualString(t, "saved", peeked[0].Message, "unexpected peeked flash")
+	testutils.RequireEqualInt(t, 1, len(consumed), "unexpected number of consumed flashes")
+	testutils.AssertEqualString(t, string(web.FlashKindError), string(consumed[0].Kind), "unexpected consumed flash kind")
+	testutils.AssertEqualString(t, "quota", consumed[0].Key, "unexpected consumed flash key")
+	testutils.AssertEqualInt(t, 0, len(remaining), "expected consumed flashes to be removed")
+	testutils.AssertContainsString(t, `<p class="flash-success">saved</p>`, body, "expected peeked flash to be rendered")
+}
+
+func serveWithCookies(t *testing.T, server *web.Server, r *http.Request, cookies []*http.Cookie) []*http.Cookie {
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, withCookies(r, cookies))
+
+	return mergeCookies(cookies, w.Result().Cookies())
+}
+
+func withCookies(r *http.Request, cookies []*http.Cookie) *http.Request {
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+
+	return r
+}
+
+func mergeCookies(current []*http.Cookie, updates []*http.Cookie) []*http.Cookie {
+	merged := make(map[string]*http.Cookie)
+	for _, cookie := range append(current, updates...) {
+		merged[cookie.Name] = cookie
+	}
+
+	cookies := make([]*http.Cookie, 0, len(merged))
+	for _, cookie := range merged {
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies
+}
@@ -0,0 +1,319 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const maxMultipartMemory = 32 << 20
+
+// FormErrors maps the name of a submitted field to the reason its value was
+// rejected. It's returned by Context.BindForm when validation fails.
+type FormErrors map[string]string
+
+func (e FormErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, field+" "+e[field])
+	}
+
+	return "invalid form: " + strings.Join(msgs, ", ")
+}
+
+// Form is exposed to templates as .Form after a call to Context.BindForm so
+// pages can be rendered again with the submitted values and their errors.
+type Form struct {
+	Values url.Values
+	Errors FormErrors
+}
+
+func (f Form) Value(name string) string {
+	return f.Values.Get(name)
+}
+
+func (f Form) Error(name string) string {
+	return f.Errors[name]
+}
+
+func (f Form) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+type formSource struct {
+	tag    string
+	values url.Values
+}
+
+// bindForm fills the fields of dst tagged with form (body), query (URL query
+// string) or path (route variables), then validates them against the rules of
+// their validate tag: required, email, min=N and max=N. min and max bound the
+// length of strings, the number of values of slices and the value of numbers.
+// Values are bound as submitted, unless the tag has the trim option as in
+// form:"email,trim", so passwords are never altered. Empty values are ignored.
+func bindForm(r *http.Request, dst interface{}) (Form, error) {
+	form := Form{Values: make(url.Values), Errors: make(FormErrors)}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return form, fmt.Errorf("can't bind form: destination must be a pointer to a struct, got %T", dst)
+	}
+
+	if err := parseForm(r); err != nil {
+		return form, fmt.Errorf("can't parse form: %v", err)
+	}
+
+	pathValues := make(url.Values)
+	for key, value := range mux.Vars(r) {
+		pathValues.Set(key, value)
+	}
+
+	sources := []formSource{
+		{tag: "form", values: r.PostForm},
+		{tag: "query", values: r.URL.Query()},
+		{tag: "path", values: pathValues},
+	}
+
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+
+		name, values, ok := lookupFormValues(field, sources)
+		if !ok {
+			continue
+		}
+		form.Values[name] = values
+
+		if msg := setFormField(elem.Field(i), values); msg != "" {
+			form.Errors[name] = msg
+			continue
+		}
+
+		msg, err := validateFormField(elem.Field(i), values, field.Tag.Get("validate"))
+		if err != nil {
+			return form, fmt.Errorf("can't validate field %s: %v", field.Name, err)
+		}
+
+		if msg != "" {
+			form.Errors[name] = msg
+		}
+	}
+
+	if !form.Valid() {
+		return form, form.Errors
+	}
+
+	return form, nil
+}
+
+func parseForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(maxMultipartMemory)
+	}
+
+	return r.ParseForm()
+}
+
+func lookupFormValues(field reflect.StructField, sources []formSource) (string, []string, bool) {
+	for _, source := range sources {
+		tag, ok := field.Tag.Lookup(source.tag)
+		name, option := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, option = tag[:i], tag[i+1:]
+		}
+		if !ok || name == "" || name == "-" {
+			continue
+		}
+
+		var values []string
+		for _, value := range source.values[name] {
+			if option == "trim" {
+				value = strings.TrimSpace(value)
+			}
+
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+
+		return name, values, true
+	}
+
+	return "", nil, false
+}
+
+// setFormField leaves field untouched when no value is submitted so defaults
+// set on the destination are kept.
+func setFormField(field reflect.Value, values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	if field.Kind() != reflect.Slice {
+		return setFormValue(field, values[0])
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for i, value := range values {
+		if msg := setFormValue(slice.Index(i), value); msg != "" {
+			return msg
+		}
+	}
+	field.Set(slice)
+
+	return ""
+}
+
+func setFormValue(field reflect.Value, value string) string {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "must be a boolean"
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return "must be an integer"
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return "must be a positive integer"
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return "must be a number"
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Sprintf("can't be bound to a %s", field.Type())
+	}
+
+	return ""
+}
+
+func validateFormField(field reflect.Value, values []string, rules string) (string, error) {
+	if rules == "" {
+		return "", nil
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		if name == "required" {
+			if len(values) == 0 {
+				return "is required", nil
+			}
+			continue
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		msg, err := validateFormRule(field, name, arg)
+		if err != nil || msg != "" {
+			return msg, err
+		}
+	}
+
+	return "", nil
+}
+
+func validateFormRule(field reflect.Value, name string, arg string) (string, error) {
+	switch name {
+	case "email":
+		return validateFormEmail(field)
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s rule argument %q", name, arg)
+		}
+		return validateFormBound(field, name, bound, arg), nil
+	default:
+		return "", fmt.Errorf("unknown validation rule %q", name)
+	}
+}
+
+// validateFormEmail checks a string, or each string of a slice, is a bare
+// email address.
+func validateFormEmail(field reflect.Value) (string, error) {
+	var addresses []string
+	switch {
+	case field.Kind() == reflect.String:
+		addresses = []string{field.String()}
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < field.Len(); i++ {
+			addresses = append(addresses, field.Index(i).String())
+		}
+	default:
+		return "", fmt.Errorf("email rule can't validate a %s", field.Type())
+	}
+
+	for _, address := range addresses {
+		addr, err := mail.ParseAddress(address)
+		if err != nil || addr.Address != address {
+			return "must be a valid email address", nil
+		}
+	}
+
+	return "", nil
+}
+
+func validateFormBound(field reflect.Value, rule string, bound float64, arg string) string {
+	var value float64
+	var unit string
+
+	switch field.Kind() {
+	case reflect.String:
+		value, unit = float64(utf8.RuneCountInString(field.String())), "characters"
+	case reflect.Slice:
+		value, unit = float64(field.Len()), "values"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = field.Float()
+	}
+
+	if rule == "min" && value < bound {
+		if unit != "" {
+			return fmt.Sprintf("must have at least %s %s", arg, unit)
+		}
+		return fmt.Sprintf("must be greater than or equal to %s", arg)
+	}
+
+	if rule == "max" && value > bound {
+		if unit != "" {
+			return fmt.Sprintf("must have at most %s %s", arg, unit)
+		}
+		return fmt.Sprintf("must be less than or equal to %s", arg)
+	}
+
+	return ""
+}
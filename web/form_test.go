@@ -0,0 +1,181 @@
+package web_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+type signupForm struct {
+	TeamID   int      `path:"team"`
+	Invite   string   `query:"invite"`
+	Email    string   `form:"email,trim" validate:"required,email"`
+	Username string   `form:"username,trim" validate:"required,min=3,max=10"`
+	Password string   `form:"password"`
+	Friends  []string `form:"friends" validate:"email"`
+	Age      int      `form:"age" validate:"min=18,max=130"`
+	Score    float64  `form:"score" validate:"max=1"`
+	Tags     []string `form:"tags" validate:"max=2"`
+	Terms    bool     `form:"terms" validate:"required"`
+	Page     int      `query:"page"`
+	Ignored  string
+}
+
+func TestBindForm(t *testing.T) {
+	server := setupServer(t)
+
+	var got signupForm
+	server.Post("/teams/{team}/signup", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		got = signupForm{Page: 1, Ignored: "untouched"}
+		if err := ctx.BindForm(r, &got); err != nil {
+			return ctx.InternalServerErrorResponse("can't bind form: %v", err)
+		}
+		return ctx.JSONResponse(http.StatusOK, "ok")
+	})
+
+	form := url.Values{
+		"email":    {" john@example.com "},
+		"username": {"john"},
+		"password": {" secret "},
+		"friends":  {"jane@example.com", "jim@example.com"},
+		"age":      {"42"},
+		"score":    {"0.5"},
+		"tags":     {"go", "web"},
+		"terms":    {"true"},
+		"Ignored":  {"overwritten"},
+	}
+	r := httptest.NewRequest("POST", "/teams/7/signup?invite=abc", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	serve(t, server, r, http.StatusOK)
+
+	testutils.AssertEqualInt(t, 7, got.TeamID, "unexpected path value")
+	testutils.AssertEqualString(t, "abc", got.Invite, "unexpected query value")
+	testutils.AssertEqualString(t, "john@example.com", got.Email, "unexpected email")
+	testutils.AssertEqualString(t, "john", got.Username, "unexpected username")
+	testutils.AssertEqualString(t, " secret ", got.Password, "expected password to be kept as submitted")
+	testutils.AssertEqualStrings(t, []string{"jane@example.com", "jim@example.com"}, got.Friends, "unexpected friends")
+	testutils.AssertEqualInt(t, 42, got.Age, "unexpected age")
+	testutils.AssertEqualFloat64(t, 0.5, got.Score, "unexpected score")
+	testutils.AssertEqualStrings(t, []string{"go", "web"}, got.Tags, "unexpected tags")
+	testutils.AssertEqualBool(t, true, got.Terms, "unexpected terms")
+	testutils.AssertEqualInt(t, 1, got.Page, "expected default value to be kept")
+	testutils.AssertEqualString(t, "untouched", got.Ignored, "expected untagged field to be ignored")
+}
+
+func TestBindFormValidation(t *testing.T) {
+	tcs := map[string]struct {
+		form       url.Values
+		wantErrors web.FormErrors
+	}{
+		"missingRequired": {
+			form:       url.Values{"terms": {"true"}},
+			wantErrors: web.FormErrors{"email": "is required", "username": "is required"},
+		},
+		"invalidEmail": {
+			form:       url.Values{"email": {"john"}, "username": {"john"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"email": "must be a valid email address"},
+		},
+		"invalidEmailInList": {
+			form:       url.Values{"email": {"john@example.com"}, "username": {"john"}, "friends": {"jane@example.com", "jim"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"friends": "must be a valid email address"},
+		},
+		"emailWithName": {
+			form:       url.Values{"email": {"John <john@example.com>"}, "username": {"john"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"email": "must be a valid email address"},
+		},
+		"lengths": {
+			form:       url.Values{"email": {"john@example.com"}, "username": {"jo"}, "tags": {"a", "b", "c"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"username": "must have at least 3 characters", "tags": "must have at most 2 values"},
+		},
+		"unicodeLength": {
+			form:       url.Values{"email": {"john@example.com"}, "username": {"ééééééééééé"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"username": "must have at most 10 characters"},
+		},
+		"ranges": {
+			form:       url.Values{"email": {"john@example.com"}, "username": {"john"}, "age": {"12"}, "score": {"1.5"}, "terms": {"true"}},
+			wantErrors: web.FormErrors{"age": "must be greater than or equal to 18", "score": "must be less than or equal to 1"},
+		},
+		"conversions": {
+			form:       url.Values{"email": {"john@example.com"}, "username": {"john"}, "age": {"old"}, "terms": {"maybe"}},
+			wantErrors: web.FormErrors{"age": "must be an integer", "terms": "must be a boolean"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			server := setupServer(t)
+
+			var formErrors web.FormErrors
+			server.Post("/teams/{team}/signup", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				var form signupForm
+				err := ctx.BindForm(r, &form)
+				if !errors.As(err, &formErrors) {
+					return ctx.InternalServerErrorResponse("expected form errors, got: %v", err)
+				}
+				return ctx.JSONResponse(http.StatusUnprocessableEntity, "invalid")
+			})
+
+			r := httptest.NewRequest("POST", "/teams/7/signup", strings.NewReader(tc.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			serve(t, server, r, http.StatusUnprocessableEntity)
+
+			testutils.AssertEqualString(t, tc.wantErrors.Error(), formErrors.Error(), "unexpected form errors")
+		})
+	}
+}
+
+func TestBindFormInvalidDestination(t *testing.T) {
+	server := setupServer(t)
+	server.Post("/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		var form signupForm
+		err := ctx.BindForm(r, form)
+		testutils.AssertErrorContains(t, "destination must be a pointer to a struct", err, "expected invalid destination error")
+
+		var badRule struct {
+			Name string `form:"name" validate:"uppercase"`
+		}
+		err = ctx.BindForm(r, &badRule)
+		testutils.AssertErrorContains(t, `unknown validation rule "uppercase"`, err, "expected unknown rule error")
+
+		var badEmail struct {
+			Name int `form:"name" validate:"email"`
+		}
+		err = ctx.BindForm(r, &badEmail)
+		testutils.AssertErrorContains(t, "email rule can't validate a int", err, "expected unsupported email rule error")
+
+		return ctx.JSONResponse(http.StatusOK, "ok")
+	})
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=42"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serve(t, server, r, http.StatusOK)
+}
+
+func TestBindFormRendersSubmittedValuesAndErrors(t *testing.T) {
+	server := setupServer(t)
+	server.Post("/teams/{team}/signup", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		var form signupForm
+		if err := ctx.BindForm(r, &form); err != nil {
+			return ctx.Response(http.StatusUnprocessableEntity, "testdata/templates/signup.html", nil)
+		}
+		return ctx.JSONResponse(http.StatusOK, "ok")
+	})
+
+	form := url.Values{"email": {`<john>`}, "username": {"john"}, "terms": {"true"}}
+	r := httptest.NewRequest("POST", "/teams/7/signup", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body := serve(t, server, r, http.StatusUnprocessableEntity)
+
+	testutils.AssertContainsString(t, `<input name="email" value="&lt;john&gt;"><span class="error">must be a valid email address</span>`, body, "expected email value and error")
+	testutils.AssertContainsString(t, `<input name="username" value="john"><`, body, "expected username value without error")
+}
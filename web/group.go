@@ -0,0 +1,98 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Group is a set of routes sharing a path prefix and a middleware stack. The
+// middlewares of a group run after the ones of its parents.
+type Group struct {
+	server      *Server
+	parent      *Group
+	router      *mux.Router
+	middlewares []Middleware
+	csrfExempt  bool
+}
+
+// Group creates a sub group of routes served under prefix.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		server: g.server,
+		parent: g,
+		router: g.router.PathPrefix(prefix).Subrouter(),
+	}
+}
+
+// Use appends middlewares to the group stack. They apply to all the routes of
+// the group, including the ones registered before the call, and must be added
+// before the server starts serving requests.
+func (g *Group) Use(mws ...Middleware) {
+	g.middlewares = append(g.middlewares, mws...)
+}
+
+// UseHTTP appends net/http middlewares which run on matched routes of the
+// group, before the session and Context are created.
+func (g *Group) UseHTTP(mws ...HTTPMiddleware) {
+	for _, mw := range mws {
+		g.router.Use(mux.MiddlewareFunc(mw))
+	}
+}
+
+func (g *Group) HandleFunc(method string, urlpath string, h HandlerFunc) *Route {
+	return g.Match([]string{method}, urlpath, h)
+}
+
+func (g *Group) Handle(method string, urlpath string, h Handler) *Route {
+	return g.HandleFunc(method, urlpath, h.Handle)
+}
+
+// Match registers h for every method of methods on urlpath.
+func (g *Group) Match(methods []string, urlpath string, h HandlerFunc) *Route {
+	route := &Route{server: g.server}
+	handler := route.withWriteTimeout(g.server.wrapRequest(g, route, g.chain(h)))
+	route.route = g.router.HandleFunc(urlpath, handler).Methods(methods...)
+
+	return route
+}
+
+func (g *Group) Get(urlpath string, h HandlerFunc) *Route {
+	return g.HandleFunc(http.MethodGet, urlpath, h)
+}
+
+func (g *Group) Post(urlpath string, h HandlerFunc) *Route {
+	return g.HandleFunc(http.MethodPost, urlpath, h)
+}
+
+func (g *Group) Put(urlpath string, h HandlerFunc) *Route {
+	return g.HandleFunc(http.MethodPut, urlpath, h)
+}
+
+func (g *Group) Patch(urlpath string, h HandlerFunc) *Route {
+	return g.HandleFunc(http.MethodPatch, urlpath, h)
+}
+
+func (g *Group) Delete(urlpath string, h HandlerFunc) *Route {
+	return g.HandleFunc(http.MethodDelete, urlpath, h)
+}
+
+// chain resolves the middleware stack when the request is served so routes
+// registered before a call to Use are wrapped as well.
+func (g *Group) chain(h HandlerFunc) HandlerFunc {
+	return func(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+		return g.wrap(h)(ctx, w, r)
+	}
+}
+
+func (g *Group) wrap(h HandlerFunc) HandlerFunc {
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		h = g.middlewares[i](h)
+	}
+
+	if g.parent != nil {
+		h = g.parent.wrap(h)
+	}
+
+	return h
+}
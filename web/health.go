@@ -0,0 +1,241 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lonepeon/golib/logger"
+)
+
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFailing = "failing"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// HealthChecker is implemented by the components reporting their health, such
+// as job.Server. A *sql.DB is checked with HealthCheckFunc(db.PingContext).
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+type HealthCheckFunc func(ctx context.Context) error
+
+func (f HealthCheckFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+// HealthReport is the body of the liveness and readiness endpoints.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// BuildInfo is the body of the build info endpoint. The VCS fields are only
+// known when the binary has been built from a repository checkout.
+type BuildInfo struct {
+	GoVersion   string `json:"go_version"`
+	Path        string `json:"path"`
+	Version     string `json:"version"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	VCSTime     string `json:"vcs_time,omitempty"`
+	VCSModified bool   `json:"vcs_modified"`
+}
+
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+}
+
+// AddLivenessCheck registers a check telling whether the process must be
+// restarted. It should not depend on external services.
+func (s *Server) AddLivenessCheck(name string, checker HealthChecker) {
+	s.livenessChecks = append(s.livenessChecks, healthCheck{name: name, checker: checker})
+}
+
+// AddReadinessCheck registers a check telling whether the server can receive
+// traffic, such as a database ping.
+func (s *Server) AddReadinessCheck(name string, checker HealthChecker) {
+	s.readinessChecks = append(s.readinessChecks, healthCheck{name: name, checker: checker})
+}
+
+// ServeHealth registers the liveness, readiness and build info endpoints
+// under prefix, as prefix/live, prefix/ready and prefix/build. They skip the
+// Middlewares registered with Use so they can't be blocked by an
+// authentication one, but still go through the HTTPMiddlewares registered with
+// UseHTTP. They never load nor save a session so probes don't get cookies.
+func (s *Server) ServeHealth(prefix string) {
+	for urlpath, h := range map[string]HandlerFunc{
+		"/live":  s.liveness,
+		"/ready": s.readiness,
+		"/build": s.buildInfo,
+	} {
+		s.router.Handle(prefix+urlpath, s.wrapHealthRequest(h)).Methods(http.MethodGet, http.MethodHead)
+	}
+}
+
+// wrapHealthRequest is the wrapRequest counterpart of the health endpoints,
+// without session, CSRF token nor locale.
+func (s *Server) wrapHealthRequest(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		traceID := requestTraceID(r)
+		reqLogger := s.requestLogger(r, traceID)
+
+		ctx := ContextImpl{
+			Context:           logger.ContextWithTraceID(r.Context(), traceID),
+			tmplConfiguration: s.tmplCfg,
+			logger:            reqLogger,
+			traceID:           traceID,
+		}
+
+		rw.Header().Add("Trace-ID", traceID)
+
+		resp, stack := s.callHandler(&ctx, rw, r, h)
+		if stack != "" {
+			reqLogger = reqLogger.WithFields(logger.String("exception.stacktrace", stack))
+		}
+
+		_, msg := s.writeResponse(&ctx, rw, r, nil, resp)
+		s.logRequest(reqLogger, r, rw, msg)
+	}
+}
+
+// SessionStoreHealthCheck checks the session store can create a session. A
+// store implementing HealthChecker is asked directly instead.
+func (s *Server) SessionStoreHealthCheck() HealthChecker {
+	if checker, ok := s.sessionStore.(HealthChecker); ok {
+		return checker
+	}
+
+	return HealthCheckFunc(func(ctx context.Context) error {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+		if err != nil {
+			return fmt.Errorf("can't build session request: %v", err)
+		}
+
+		if _, err := s.sessionStore.New(r, s.options.SessionCookieName); err != nil {
+			return fmt.Errorf("can't create session: %v", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) liveness(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+	return s.healthResponse(ctx, r, s.livenessChecks)
+}
+
+func (s *Server) readiness(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+	checks := s.readinessChecks
+	select {
+	case <-s.shuttingDown:
+		checks = append([]healthCheck{{name: "server", checker: HealthCheckFunc(func(context.Context) error {
+			return ErrShuttingDown
+		})}}, checks...)
+	default:
+	}
+
+	return s.healthResponse(ctx, r, checks)
+}
+
+func (s *Server) buildInfo(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+	return ctx.JSONResponse(http.StatusOK, ReadBuildInfo())
+}
+
+// healthResponse runs the checks concurrently, each one being limited by the
+// health check timeout.
+func (s *Server) healthResponse(ctx Context, r *http.Request, checks []healthCheck) Response {
+	report := HealthReport{Status: HealthStatusOK, Checks: make(map[string]HealthCheckResult, len(checks))}
+
+	var l sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+
+			result := runHealthCheck(r.Context(), s.options.HealthCheckTimeout, check.checker)
+
+			l.Lock()
+			defer l.Unlock()
+			report.Checks[check.name] = result
+			if result.Status != HealthStatusOK {
+				report.Status = HealthStatusFailing
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if report.Status != HealthStatusOK {
+		resp := ctx.JSONResponse(http.StatusServiceUnavailable, report)
+		resp.LogMessage = "health checks failing: " + failingChecks(report)
+
+		return resp.WithHeader("Cache-Control", "no-store")
+	}
+
+	return ctx.JSONResponse(http.StatusOK, report).WithHeader("Cache-Control", "no-store")
+}
+
+func runHealthCheck(ctx context.Context, timeout time.Duration, checker HealthChecker) HealthCheckResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := checker.CheckHealth(ctx)
+	result := HealthCheckResult{
+		Status:    HealthStatusOK,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	if err != nil {
+		result.Status = HealthStatusFailing
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func failingChecks(report HealthReport) string {
+	var names []string
+	for name, result := range report.Checks {
+		if result.Status != HealthStatusOK {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// ReadBuildInfo reports the main module version and the VCS revision embedded
+// by the go command in the binary.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Path = buildInfo.Main.Path
+	info.Version = buildInfo.Main.Version
+	readVCSInfo(&info, buildInfo)
+
+	return info
+}
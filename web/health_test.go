@@ -0,0 +1,108 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestHealthReadiness(t *testing.T) {
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates:          testTmplConfiguration(),
+		HealthCheckTimeout: 10 * time.Millisecond,
+	})
+	server.AddReadinessCheck("sqlite", web.HealthCheckFunc(func(context.Context) error { return nil }))
+	server.AddReadinessCheck("jobs", web.HealthCheckFunc(func(context.Context) error { return errors.New("job server is not running") }))
+	server.AddReadinessCheck("slow", web.HealthCheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	server.ServeHealth("/_health")
+
+	report := serveHealthReport(t, server, "/_health/ready", http.StatusServiceUnavailable)
+
+	testutils.AssertEqualString(t, web.HealthStatusFailing, report.Status, "unexpected status")
+	testutils.RequireEqualInt(t, 3, len(report.Checks), "unexpected number of checks")
+	testutils.AssertEqualString(t, web.HealthStatusOK, report.Checks["sqlite"].Status, "unexpected sqlite status")
+	testutils.AssertEqualString(t, "", report.Checks["sqlite"].Error, "unexpected sqlite error")
+	testutils.AssertEqualString(t, web.HealthStatusFailing, report.Checks["jobs"].Status, "unexpected jobs status")
+	testutils.AssertEqualString(t, "job server is not running", report.Checks["jobs"].Error, "unexpected jobs error")
+	testutils.AssertEqualString(t, "context deadline exceeded", report.Checks["slow"].Error, "unexpected slow check error")
+	testutils.AssertEqualBool(t, true, report.Checks["slow"].LatencyMS >= 10, "unexpected slow check latency: %v", report.Checks["slow"].LatencyMS)
+}
+
+func TestHealthLiveness(t *testing.T) {
+	server := setupServer(t)
+	server.Use(func(next web.HandlerFunc) web.HandlerFunc {
+		return func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+			return ctx.JSONErrorResponse(http.StatusUnauthorized, "authentication required")
+		}
+	})
+	server.AddLivenessCheck("sessions", server.SessionStoreHealthCheck())
+	server.ServeHealth("/_health")
+
+	report := serveHealthReport(t, server, "/_health/live", http.StatusOK)
+
+	testutils.AssertEqualString(t, web.HealthStatusOK, report.Status, "unexpected status")
+	testutils.AssertEqualString(t, web.HealthStatusOK, report.Checks["sessions"].Status, "unexpected sessions status")
+}
+
+func TestHealthReadinessDuringShutdown(t *testing.T) {
+	server := setupServer(t)
+	server.ServeHealth("/_health")
+	serveHealthReport(t, server, "/_health/ready", http.StatusOK)
+
+	testutils.RequireNoError(t, server.Shutdown(context.Background()), "can't shutdown server")
+
+	report := serveHealthReport(t, server, "/_health/ready", http.StatusServiceUnavailable)
+	testutils.AssertEqualString(t, web.ErrShuttingDown.Error(), report.Checks["server"].Error, "unexpected server error")
+}
+
+func TestHealthBuildInfo(t *testing.T) {
+	server := setupServer(t)
+	server.ServeHealth("/_health")
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/_health/build", nil))
+	testutils.RequireEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+
+	var info web.BuildInfo
+	testutils.RequireNoError(t, json.Unmarshal(w.Body.Bytes(), &info), "can't decode build info")
+	testutils.AssertEqualString(t, runtime.Version(), info.GoVersion, "unexpected go version")
+}
+
+func TestHealthDoesntSetCookies(t *testing.T) {
+	server := setupServer(t)
+	server.EnableCSRFProtection()
+	server.ServeHealth("/_health")
+
+	for _, target := range []string{"/_health/live", "/_health/ready", "/_health/build"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+
+		testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code for %s", target)
+		testutils.AssertEqualString(t, "", w.Header().Get("Set-Cookie"), "unexpected cookie for %s", target)
+	}
+}
+
+func serveHealthReport(t *testing.T, server *web.Server, target string, wantCode int) web.HealthReport {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	testutils.RequireEqualInt(t, wantCode, w.Code, "unexpected http code: %s", w.Body.String())
+	testutils.AssertEqualString(t, "no-store", w.Header().Get("Cache-Control"), "unexpected cache control")
+
+	var report web.HealthReport
+	testutils.RequireNoError(t, json.Unmarshal(w.Body.Bytes(), &report), "can't decode health report")
+
+	return report
+}
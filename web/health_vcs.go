@@ -0,0 +1,23 @@
+//go:build go1.18
+// +build go1.18
+
+package web
+
+import (
+	"runtime/debug"
+)
+
+// readVCSInfo fills the VCS fields from the build settings, only recorded by
+// the go command since Go 1.18.
+func readVCSInfo(info *BuildInfo, buildInfo *debug.BuildInfo) {
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.VCSRevision = setting.Value
+		case "vcs.time":
+			info.VCSTime = setting.Value
+		case "vcs.modified":
+			info.VCSModified = setting.Value == "true"
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package web
+
+import (
+	"runtime/debug"
+)
+
+// readVCSInfo leaves the VCS fields empty since binaries built before Go 1.18
+// don't record them.
+func readVCSInfo(info *BuildInfo, buildInfo *debug.BuildInfo) {}
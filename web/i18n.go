@@ -0,0 +1,287 @@
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocaleCookieName is the cookie read to find the locale chosen by the user.
+const LocaleCookieName = "locale"
+
+var ErrInvalidCatalog = errors.New("invalid message catalog")
+
+//go:embed locales
+var builtinLocalesFS embed.FS
+
+// builtinCatalog translates the messages of the web package itself, such as
+// the authentication flashes. Application catalogs can override its keys.
+var builtinCatalog = mustLoadCatalog(builtinLocalesFS, "locales", "en")
+
+// Catalog holds the messages of every locale. Messages can reference
+// parameters by name, as in "Hello {name}".
+type Catalog struct {
+	defaultLocale string
+	locales       []string
+	messages      map[string]map[string]string
+}
+
+// LoadCatalog reads the JSON files of dir, one per locale, named after the
+// locale they translate such as en.json or fr-CA.json. Each file is an object
+// mapping message keys to messages.
+func LoadCatalog(fsys fs.FS, dir string, defaultLocale string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("can't list catalog files: %v", err)
+	}
+
+	c := &Catalog{defaultLocale: defaultLocale, messages: make(map[string]map[string]string)}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("can't read catalog file %s: %v", file, err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("%w: can't decode %s: %v", ErrInvalidCatalog, file, err)
+		}
+
+		locale := strings.TrimSuffix(path.Base(file), ".json")
+		c.messages[locale] = messages
+		c.locales = append(c.locales, locale)
+	}
+	sort.Strings(c.locales)
+
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("%w: no messages for default locale %s", ErrInvalidCatalog, defaultLocale)
+	}
+
+	return c, nil
+}
+
+func mustLoadCatalog(fsys fs.FS, dir string, defaultLocale string) *Catalog {
+	c, err := LoadCatalog(fsys, dir, defaultLocale)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+func (c *Catalog) Locales() []string {
+	return c.locales
+}
+
+// Translate formats the message key of locale with params, given as name/value
+// pairs. Messages missing from locale are looked up in its base language, then
+// in the default locale. The key is returned when no message is found.
+func (c *Catalog) Translate(locale string, key string, params ...interface{}) string {
+	return translate(c, locale, key, translationParams(params))
+}
+
+func (c *Catalog) lookup(locale string, key string) (string, bool) {
+	candidates := []string{locale}
+	if base := baseLanguage(locale); base != locale {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, c.defaultLocale)
+
+	for _, candidate := range candidates {
+		if msg, ok := c.messages[c.supportedLocale(candidate)][key]; ok {
+			return msg, true
+		}
+	}
+
+	return "", false
+}
+
+// supportedLocale returns the locale of the catalog matching locale, ignoring
+// the case, or an empty string.
+func (c *Catalog) supportedLocale(locale string) string {
+	for _, supported := range c.locales {
+		if strings.EqualFold(supported, locale) {
+			return supported
+		}
+	}
+
+	return ""
+}
+
+// resolve returns the locale of the catalog matching locale exactly, or else by
+// language, or else the default locale.
+func (c *Catalog) resolve(locale string) string {
+	if supported, ok := c.negotiate(locale); ok {
+		return supported
+	}
+
+	return c.defaultLocale
+}
+
+// negotiate picks the locale of the catalog preferred by the Accept-Language
+// header. A tag matches a locale of the same language when no locale matches it exactly.
+func (c *Catalog) negotiate(acceptLanguage string) (string, bool) {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if locale := c.supportedLocale(tag); locale != "" {
+			return locale, true
+		}
+
+		for _, supported := range c.locales {
+			if strings.EqualFold(baseLanguage(supported), baseLanguage(tag)) {
+				return supported, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// translateFlashes fills the message of the flashes created with a
+// translation key.
+func translateFlashes(c *Catalog, locale string, flashes []interface{}) []interface{} {
+	for i, flash := range flashes {
+		switch f := flash.(type) {
+		case *FlashMessage:
+			translated := f.translate(c, locale)
+			flashes[i] = &translated
+		case FlashMessage:
+			flashes[i] = f.translate(c, locale)
+		}
+	}
+
+	return flashes
+}
+
+// translate looks key up in the application catalog, when there's one, then in
+// the built-in one.
+func translate(c *Catalog, locale string, key string, params map[string]string) string {
+	msg, ok := "", false
+	if c != nil {
+		msg, ok = c.lookup(locale, key)
+	}
+
+	if !ok {
+		msg, ok = builtinCatalog.lookup(locale, key)
+	}
+
+	if !ok {
+		return key
+	}
+
+	for name, value := range params {
+		msg = strings.ReplaceAll(msg, "{"+name+"}", value)
+	}
+
+	return msg
+}
+
+// translationParams turns name/value pairs into a map. A name without value is
+// ignored.
+func translationParams(pairs []interface{}) map[string]string {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		params[fmt.Sprint(pairs[i])] = fmt.Sprint(pairs[i+1])
+	}
+
+	return params
+}
+
+func baseLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		return locale[:i]
+	}
+
+	return locale
+}
+
+// parseAcceptLanguage returns the language tags sorted by decreasing quality.
+// The wildcard and the tags with a zero quality are left out.
+func parseAcceptLanguage(acceptLanguage string) []string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []languageRange
+	for _, value := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(strings.TrimSpace(value), ";")
+		tag := strings.TrimSpace(parts[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			if err != nil {
+				q = 0
+			}
+			quality = q
+		}
+
+		if quality > 0 {
+			ranges = append(ranges, languageRange{tag: tag, quality: quality})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	tags := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		tags = append(tags, r.tag)
+	}
+
+	return tags
+}
+
+// EnableI18n translates templates and flashes with c. Templates get the request
+// locale as .Locale, to give to the t func as in {{ t $.Locale "key" "name" .Name }}.
+// Without catalog, t only knows the messages of the built-in one.
+func (s *Server) EnableI18n(c *Catalog) {
+	s.catalog = c
+	s.tmplFuncs["t"] = c.Translate
+	s.tmplCache.reset()
+}
+
+// negotiateLocale finds the locale of r from the locale cookie, then the
+// Accept-Language header. Context.SetLocale can override it, for instance with
+// the preference stored in a user profile. Without catalog, everything is
+// rendered in the default locale of the built-in one.
+func (s *Server) negotiateLocale(r *http.Request) string {
+	catalog := s.catalog
+	if catalog == nil {
+		return builtinCatalog.defaultLocale
+	}
+
+	if cookie, err := r.Cookie(LocaleCookieName); err == nil {
+		if locale := catalog.supportedLocale(cookie.Value); locale != "" {
+			return locale
+		}
+	}
+
+	if locale, ok := catalog.negotiate(r.Header.Get("Accept-Language")); ok {
+		return locale
+	}
+
+	return catalog.defaultLocale
+}
@@ -0,0 +1,157 @@
+package web_test
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+//go:embed testdata/locales
+var localesFS embed.FS
+
+func TestCatalogTranslate(t *testing.T) {
+	catalog, err := web.LoadCatalog(localesFS, "testdata/locales", "en")
+	testutils.RequireNoError(t, err, "can't load catalog")
+
+	testutils.AssertEqualStrings(t, []string{"en", "fr"}, catalog.Locales(), "unexpected locales")
+
+	tcs := map[string]struct {
+		locale string
+		key    string
+		params []interface{}
+		want   string
+	}{
+		"withParams":           {locale: "fr", key: "greeting", params: []interface{}{"name", "Jane"}, want: "Bonjour Jane"},
+		"baseLanguage":         {locale: "fr-CA", key: "greeting", params: []interface{}{"name", "Jane"}, want: "Bonjour Jane"},
+		"missingInLocale":      {locale: "fr", key: "farewell", want: "Goodbye"},
+		"unsupportedLocale":    {locale: "de", key: "farewell", want: "Goodbye"},
+		"missingKey":           {locale: "fr", key: "unknown", want: "unknown"},
+		"builtinKey":           {locale: "fr", key: "web.authentication.missing_credentials", want: "le nom d'utilisateur et le mot de passe sont requis"},
+		"overriddenBuiltinKey": {locale: "en", key: "web.authentication.invalid_credentials", want: "wrong username or password"},
+		"paramWithoutAValue":   {locale: "en", key: "greeting", params: []interface{}{"name"}, want: "Hello {name}"},
+		"nonStringParamValues": {locale: "en", key: "greeting", params: []interface{}{"name", 42}, want: "Hello 42"},
+		"unusedParams":         {locale: "en", key: "farewell", params: []interface{}{"name", "Jane"}, want: "Goodbye"},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			testutils.AssertEqualString(t, tc.want, catalog.Translate(tc.locale, tc.key, tc.params...), "unexpected translation")
+		})
+	}
+}
+
+func TestLoadCatalogErrors(t *testing.T) {
+	tcs := map[string]fstest.MapFS{
+		"invalidJSON":          {"locales/en.json": {Data: []byte(`{"greeting": 42}`)}},
+		"missingDefaultLocale": {"locales/fr.json": {Data: []byte(`{"greeting": "Bonjour"}`)}},
+	}
+
+	for name, fsys := range tcs {
+		fsys := fsys
+		t.Run(name, func(t *testing.T) {
+			_, err := web.LoadCatalog(fsys, "locales", "en")
+			testutils.AssertErrorIs(t, web.ErrInvalidCatalog, err, "unexpected error")
+		})
+	}
+}
+
+func TestServerLocaleNegotiation(t *testing.T) {
+	tcs := map[string]struct {
+		acceptLanguage string
+		cookie         string
+		preference     string
+		want           string
+	}{
+		"default":             {want: `<p lang="en">Hello Jane</p>`},
+		"acceptLanguage":      {acceptLanguage: "de;q=0.9,fr-CA,en;q=0.8", want: `<p lang="fr">Bonjour Jane</p>`},
+		"unsupportedLanguage": {acceptLanguage: "de", want: `<p lang="en">Hello Jane</p>`},
+		"cookie":              {acceptLanguage: "fr", cookie: "en", want: `<p lang="en">Hello Jane</p>`},
+		"unsupportedCookie":   {acceptLanguage: "fr", cookie: "de", want: `<p lang="fr">Bonjour Jane</p>`},
+		"userPreference":      {acceptLanguage: "en", cookie: "en", preference: "fr-BE", want: `<p lang="fr">Bonjour Jane</p>`},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			server := setupI18nServer(t)
+			server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				if tc.preference != "" {
+					ctx.SetLocale(tc.preference)
+				}
+
+				return ctx.Response(http.StatusOK, "testdata/templates/i18n.html", map[string]interface{}{"Name": "Jane"})
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: web.LocaleCookieName, Value: tc.cookie})
+			}
+
+			body := serve(t, server, r, http.StatusOK)
+
+			testutils.AssertContainsString(t, tc.want, body, "unexpected body")
+		})
+	}
+}
+
+func TestServerTranslatesFlashes(t *testing.T) {
+	server := setupI18nServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewTranslatedFlashMessageSuccess("greeting", "name", "Jane"))
+		ctx.AddFlash(web.NewTranslatedFlashMessageError("web.authentication.missing_credentials"))
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr")
+	body := serve(t, server, r, http.StatusOK)
+
+	testutils.AssertContainsString(t, `<p class="flash-success">Bonjour Jane</p>`, body, "expected translated flash")
+	testutils.AssertContainsString(t, `<p class="flash-error">le nom d&#39;utilisateur et le mot de passe sont requis</p>`, body, "expected built-in translated flash")
+}
+
+func TestServerTranslatesFlashesWithoutCatalog(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewTranslatedFlashMessageError("web.authentication.invalid_credentials"))
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr")
+	body := serve(t, server, r, http.StatusOK)
+
+	testutils.AssertContainsString(t, `<p class="flash-error">username/password combination is invalid</p>`, body, "expected built-in english flash")
+}
+
+func TestServerTranslatesTemplatesWithoutCatalog(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/i18n.html", map[string]interface{}{"Name": "Jane"})
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr")
+	body := serve(t, server, r, http.StatusOK)
+
+	testutils.AssertContainsString(t, `<p lang="en">greeting</p>`, body, "expected untranslated key")
+}
+
+func setupI18nServer(t *testing.T) *web.Server {
+	catalog, err := web.LoadCatalog(localesFS, "testdata/locales", "en")
+	testutils.RequireNoError(t, err, "can't load catalog")
+
+	server := setupServer(t)
+	server.EnableI18n(catalog)
+
+	return server
+}
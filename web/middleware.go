@@ -0,0 +1,59 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps a HandlerFunc. It runs inside the request wrapper so it has
+// access to the Context and its returned Response is rendered and logged like
+// any other.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// HTTPMiddleware wraps the plain net/http handler.
+type HTTPMiddleware func(http.Handler) http.Handler
+
+type timeoutContextKey struct{}
+
+// Timeout cancels the request context after d. A handler returning after the
+// deadline gets its response replaced by a 503 Service Unavailable, rendered
+// with the error layout or as JSON depending on the Accept header. Handlers
+// aren't interrupted, so they must stop working once the context is done.
+// Event streams are cut after d as well, so Timeout belongs to the groups
+// which don't stream.
+func Timeout(d time.Duration) HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, timeoutContextKey{}, d)))
+		})
+	}
+}
+
+// timedOut reports whether the deadline set by the Timeout middleware expired.
+func timedOut(r *http.Request) bool {
+	ctx := r.Context()
+	_, ok := ctx.Value(timeoutContextKey{}).(time.Duration)
+
+	return ok && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// SecurityHeaders sets a conservative set of security related headers. They
+// are set before calling the next handler so a route can still override them.
+func SecurityHeaders() HTTPMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers := w.Header()
+			headers.Set("X-Content-Type-Options", "nosniff")
+			headers.Set("X-Frame-Options", "DENY")
+			headers.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			headers.Set("Cross-Origin-Opener-Policy", "same-origin")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestServerMiddlewaresOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) web.Middleware {
+		return func(next web.HandlerFunc) web.HandlerFunc {
+			return func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				calls = append(calls, name)
+				return next(ctx, w, r)
+			}
+		}
+	}
+
+	server := setupServer(t)
+	admin := server.Group("/admin")
+	users := admin.Group("/users")
+	users.HandleFunc("GET", "/{id}", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		calls = append(calls, "handler")
+		return ctx.JSONResponse(http.StatusOK, ctx.Vars(r))
+	})
+
+	users.Use(record("users"))
+	admin.Use(record("admin-1"), record("admin-2"))
+	server.Use(record("server"))
+
+	body := serve(t, server, httptest.NewRequest("GET", "/admin/users/42", nil), http.StatusOK)
+
+	testutils.AssertEqualString(t, `{"id":"42"}`+"\n", body, "unexpected body")
+	testutils.AssertEqualStrings(t, []string{"server", "admin-1", "admin-2", "users", "handler"}, calls, "unexpected middlewares order")
+}
+
+func TestServerGroupMiddlewaresAreScoped(t *testing.T) {
+	var calls []string
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "public")
+	})
+
+	admin := server.Group("/admin")
+	admin.Use(func(next web.HandlerFunc) web.HandlerFunc {
+		return func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+			calls = append(calls, r.URL.Path)
+			return ctx.JSONErrorResponse(http.StatusForbidden, "forbidden")
+		}
+	})
+	admin.UseHTTP(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Admin", "true")
+			next.ServeHTTP(w, r)
+		})
+	})
+	admin.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "admin")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected public http code")
+	testutils.AssertEqualString(t, "", w.Header().Get("X-Admin"), "unexpected group header on public route")
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+	testutils.AssertEqualInt(t, http.StatusForbidden, w.Code, "unexpected admin http code")
+	testutils.AssertEqualString(t, "true", w.Header().Get("X-Admin"), "expected group header on admin route")
+
+	testutils.AssertEqualStrings(t, []string{"/admin/"}, calls, "unexpected middleware calls")
+}
+
+func TestServerHTTPMiddlewaresWrapUnknownRoutes(t *testing.T) {
+	server := setupServer(t)
+	server.UseHTTP(web.SecurityHeaders())
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+
+	testutils.AssertEqualInt(t, http.StatusNotFound, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "nosniff", w.Header().Get("X-Content-Type-Options"), "expected security headers")
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	server := setupServer(t)
+	server.UseHTTP(web.SecurityHeaders())
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "ok").WithHeader("X-Frame-Options", "SAMEORIGIN")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	testutils.AssertEqualString(t, "nosniff", w.Header().Get("X-Content-Type-Options"), "unexpected content type options")
+	testutils.AssertEqualString(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"), "unexpected referrer policy")
+	testutils.AssertEqualString(t, "same-origin", w.Header().Get("Cross-Origin-Opener-Policy"), "unexpected opener policy")
+	testutils.AssertEqualStrings(t, []string{"SAMEORIGIN"}, w.Header().Values("X-Frame-Options"), "expected route to override frame options")
+}
+
+func TestTimeout(t *testing.T) {
+	server := setupServer(t)
+	server.UseHTTP(web.Timeout(10 * time.Millisecond))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		<-ctx.StdCtx().Done()
+		return ctx.JSONErrorResponse(http.StatusServiceUnavailable, "too slow")
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusServiceUnavailable)
+	testutils.AssertContainsString(t, `<body class="error">`, body, "expected timeout to be rendered with the error layout")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	body = serve(t, server, r, http.StatusServiceUnavailable)
+	testutils.AssertEqualString(t, `{"error":{"status":503,"message":"request timed out"}}`+"\n", body, "unexpected json body")
+}
+
+func TestTimeoutKeepsFlusher(t *testing.T) {
+	server := setupServer(t)
+	server.UseHTTP(web.Timeout(time.Second))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		_, ok := w.(http.Flusher)
+		return ctx.JSONResponse(http.StatusOK, ok)
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.AssertEqualString(t, "true\n", body, "expected response writer to be flushable")
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateFormat picks the response format preferred by the Accept header.
+// HTML is returned unless JSON is strictly preferred, so browsers sending
+// "*/*" keep getting pages.
+func negotiateFormat(accept string) ResponseFormat {
+	ranges := parseAccept(accept)
+
+	if acceptQuality(ranges, "application/json") > acceptQuality(ranges, "text/html") {
+		return ResponseFormatJSON
+	}
+
+	return ResponseFormatHTML
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching mediaType.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	var quality float64
+	specificity := -1
+
+	for _, r := range ranges {
+		s := r.specificity(mediaType)
+		if s > specificity {
+			quality = r.quality
+			specificity = s
+		}
+	}
+
+	return quality
+}
+
+func (r acceptRange) specificity(mediaType string) int {
+	if r.mediaType == mediaType {
+		return 2
+	}
+
+	if r.mediaType == "*/*" {
+		return 0
+	}
+
+	if strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*")) {
+		return 1
+	}
+
+	return -1
+}
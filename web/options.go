@@ -0,0 +1,167 @@
+package web
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidServerOptions = errors.New("invalid server options")
+
+const (
+	DefaultSessionCookieName    = "trax"
+	DefaultAuthCookieName       = "auth"
+	DefaultReadTimeout          = 30 * time.Second
+	DefaultReadHeaderTimeout    = 10 * time.Second
+	DefaultWriteTimeout         = 45 * time.Second
+	DefaultMaxHeaderBytes       = http.DefaultMaxHeaderBytes
+	DefaultMaxBodyBytes         = 10 << 20
+	DefaultEventStreamHeartbeat = 15 * time.Second
+	DefaultHealthCheckTimeout   = 5 * time.Second
+
+	// NoMaxBodyBytes disables the limit of request bodies, for instance when a
+	// proxy in front of the server already enforces one.
+	NoMaxBodyBytes = -1
+)
+
+// ServerOptions configures a Server. Zero values are replaced by their default:
+// cookie names and timeouts use the Default constants, the error layout falls
+// back to the main layout and the method not allowed template to the not found one.
+type ServerOptions struct {
+	Templates         TmplConfiguration
+	SessionCookieName string
+	AuthCookieName    string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxBodyBytes limits the size of request bodies, uploads included. It's
+	// disabled with NoMaxBodyBytes.
+	MaxBodyBytes int64
+	// TLSConfig is used by ListenAndServeTLS and ServeTLS. When it provides
+	// certificates, the certificate and key files can be left empty.
+	TLSConfig *tls.Config
+	AccessLog AccessLogOptions
+	// EventStreamHeartbeat is the interval between two heartbeats sent on
+	// idle event streams.
+	EventStreamHeartbeat time.Duration
+	// HealthCheckTimeout limits the duration of each health check.
+	HealthCheckTimeout time.Duration
+}
+
+func (o ServerOptions) withDefaults() ServerOptions {
+	if o.SessionCookieName == "" {
+		o.SessionCookieName = DefaultSessionCookieName
+	}
+
+	if o.AuthCookieName == "" {
+		o.AuthCookieName = DefaultAuthCookieName
+	}
+
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = DefaultReadTimeout
+	}
+
+	if o.ReadHeaderTimeout == 0 {
+		o.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = DefaultWriteTimeout
+	}
+
+	if o.MaxHeaderBytes == 0 {
+		o.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+
+	if o.MaxBodyBytes == 0 {
+		o.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	if o.EventStreamHeartbeat == 0 {
+		o.EventStreamHeartbeat = DefaultEventStreamHeartbeat
+	}
+
+	if o.HealthCheckTimeout == 0 {
+		o.HealthCheckTimeout = DefaultHealthCheckTimeout
+	}
+
+	if o.AccessLog.SampleRate == 0 {
+		o.AccessLog.SampleRate = DefaultAccessLogSampleRate
+	}
+
+	if o.Templates.MethodNotAllowedTemplate == "" {
+		o.Templates.MethodNotAllowedTemplate = o.Templates.NotFoundTemplate
+	}
+
+	return o
+}
+
+func (o ServerOptions) validate() error {
+	var problems []string
+
+	for name, value := range map[string]string{"session cookie name": o.SessionCookieName, "auth cookie name": o.AuthCookieName} {
+		if !isValidCookieName(value) {
+			problems = append(problems, fmt.Sprintf("%s %q is not a valid cookie name", name, value))
+		}
+	}
+
+	if o.SessionCookieName == o.AuthCookieName {
+		problems = append(problems, fmt.Sprintf("session and auth cookies can't share the same name %q", o.SessionCookieName))
+	}
+
+	for name, value := range map[string]time.Duration{"read timeout": o.ReadTimeout, "read header timeout": o.ReadHeaderTimeout, "write timeout": o.WriteTimeout, "idle timeout": o.IdleTimeout, "event stream heartbeat": o.EventStreamHeartbeat, "health check timeout": o.HealthCheckTimeout} {
+		if value < 0 {
+			problems = append(problems, fmt.Sprintf("%s can't be negative", name))
+		}
+	}
+
+	if o.ReadHeaderTimeout > o.ReadTimeout {
+		problems = append(problems, "read header timeout can't be greater than read timeout")
+	}
+
+	if o.MaxHeaderBytes < 0 {
+		problems = append(problems, "max header bytes can't be negative")
+	}
+
+	if o.MaxBodyBytes < 0 && o.MaxBodyBytes != NoMaxBodyBytes {
+		problems = append(problems, "max body bytes can't be negative")
+	}
+
+	if o.AccessLog.SampleRate < 0 || o.AccessLog.SampleRate > 1 {
+		problems = append(problems, "access log sample rate must be between 0 and 1")
+	}
+
+	for name, value := range map[string]string{"layout": o.Templates.Layout, "redirection template": o.Templates.RedirectionTemplate, "not found template": o.Templates.NotFoundTemplate, "internal server error template": o.Templates.InternalServerErrorTemplate} {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", ErrInvalidServerOptions, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// isValidCookieName checks name is a token as defined by RFC 7230.
+func isValidCookieName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestNewServerWithOptionsDefaults(t *testing.T) {
+	tmplCfg := testTmplConfiguration()
+	tmplCfg.ErrorLayout = ""
+	server := newTestServerWithOptions(t, web.ServerOptions{Templates: tmplCfg})
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.NotFoundResponse("not found")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	testutils.AssertEqualInt(t, http.StatusNotFound, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "<html><body>not found</body></html>", strings.TrimSpace(w.Body.String()), "expected main layout as error layout")
+	testutils.AssertEqualStrings(t, []string{web.DefaultSessionCookieName}, cookieNames(w), "unexpected cookies")
+}
+
+func TestNewServerWithOptionsCookieNames(t *testing.T) {
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates:         testTmplConfiguration(),
+		SessionCookieName: "myapp_session",
+		AuthCookieName:    "myapp_auth",
+	})
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		if err := server.AuthenticationSessionStore().StoreUserID(w, r, web.AuthenticationUserID("42")); err != nil {
+			return ctx.InternalServerErrorResponse("can't store user: %v", err)
+		}
+		return ctx.JSONResponse(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+	testutils.AssertEqualStrings(t, []string{"myapp_auth", "myapp_session"}, cookieNames(w), "unexpected cookies")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	id, err := server.AuthenticationSessionStore().CurrentUserID(r)
+	testutils.RequireNoError(t, err, "can't get current user")
+	testutils.AssertEqualString(t, "42", id.String(), "unexpected current user")
+}
+
+func TestNewServerKeepsEmptyErrorLayout(t *testing.T) {
+	tmplCfg := testTmplConfiguration()
+	tmplCfg.ErrorLayout = ""
+	server := newTestServer(t, tmplCfg)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.NotFoundResponse("not found")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	testutils.AssertEqualInt(t, http.StatusNotFound, w.Code, "unexpected http code")
+	testutils.AssertEqualBool(t, false, strings.Contains(w.Body.String(), "<body>"), "expected error page without layout: %s", w.Body.String())
+}
+
+func TestNewServerWithOptionsValidation(t *testing.T) {
+	tcs := map[string]struct {
+		configure func(*web.ServerOptions)
+		wantError string
+	}{
+		"invalidSessionCookieName": {
+			configure: func(o *web.ServerOptions) { o.SessionCookieName = "my session" },
+			wantError: `session cookie name "my session" is not a valid cookie name`,
+		},
+		"invalidAuthCookieName": {
+			configure: func(o *web.ServerOptions) { o.AuthCookieName = "auth;" },
+			wantError: `auth cookie name "auth;" is not a valid cookie name`,
+		},
+		"sameCookieNames": {
+			configure: func(o *web.ServerOptions) { o.SessionCookieName = "app"; o.AuthCookieName = "app" },
+			wantError: `session and auth cookies can't share the same name "app"`,
+		},
+		"negativeTimeout": {
+			configure: func(o *web.ServerOptions) { o.WriteTimeout = -time.Second },
+			wantError: "write timeout can't be negative",
+		},
+		"readHeaderTimeoutTooLong": {
+			configure: func(o *web.ServerOptions) { o.ReadTimeout = time.Second; o.ReadHeaderTimeout = time.Minute },
+			wantError: "read header timeout can't be greater than read timeout",
+		},
+		"negativeMaxHeaderBytes": {
+			configure: func(o *web.ServerOptions) { o.MaxHeaderBytes = -1 },
+			wantError: "max header bytes can't be negative",
+		},
+		"negativeMaxBodyBytes": {
+			configure: func(o *web.ServerOptions) { o.MaxBodyBytes = -2 },
+			wantError: "max body bytes can't be negative",
+		},
+		"accessLogSampleRateAboveOne": {
+			configure: func(o *web.ServerOptions) { o.AccessLog.SampleRate = 1.5 },
+			wantError: "access log sample rate must be between 0 and 1",
+		},
+		"missingLayout": {
+			configure: func(o *web.ServerOptions) { o.Templates.Layout = "" },
+			wantError: "layout is required",
+		},
+		"missingNotFoundTemplate": {
+			configure: func(o *web.ServerOptions) { o.Templates.NotFoundTemplate = "" },
+			wantError: "not found template is required",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			log, _, closer := loggertest.NewFake(t)
+			t.Cleanup(closer)
+
+			opts := web.ServerOptions{Templates: testTmplConfiguration()}
+			tc.configure(&opts)
+
+			_, err := web.NewServerWithOptions(log, sessions.NewCookieStore([]byte("secret-key")), opts)
+
+			testutils.AssertErrorIs(t, web.ErrInvalidServerOptions, err, "expected invalid options")
+			testutils.AssertErrorContains(t, tc.wantError, err, "unexpected validation error")
+		})
+	}
+}
+
+func newTestServerWithOptions(t *testing.T, opts web.ServerOptions) *web.Server {
+	log, _, closer := loggertest.NewFake(t)
+	t.Cleanup(closer)
+
+	server, err := web.NewServerWithOptions(log, sessions.NewCookieStore([]byte("secret-key")), opts)
+	testutils.RequireNoError(t, err, "can't create server")
+
+	return server
+}
+
+func cookieNames(w *httptest.ResponseRecorder) []string {
+	var names []string
+	for _, cookie := range w.Result().Cookies() {
+		names = append(names, cookie.Name)
+	}
+
+	return names
+}
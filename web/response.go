@@ -1,11 +1,53 @@
 package web
 
+import (
+	"net/http"
+)
+
+type ResponseFormat int
+
+const (
+	ResponseFormatHTML ResponseFormat = iota
+	ResponseFormatJSON
+	ResponseFormatEventStream
+)
+
 type Response struct {
 	HTTPCode   int
+	Format     ResponseFormat
+	Headers    http.Header
 	Layout     string
 	LogMessage string
 	Data       interface{}
 	Template   string
+	// FlashKeys lists the scopes of the flashes consumed by the page, the empty
+	// key being the one of unscoped flashes. Only unscoped flashes are consumed
+	// when it's nil.
+	FlashKeys []string
+
+	eventStream EventStreamFunc
+}
+
+// WithHeader returns a copy of the response which sets the header key to value
+// when written.
+func (r Response) WithHeader(key string, value string) Response {
+	headers := r.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(key, value)
+	r.Headers = headers
+
+	return r
+}
+
+// WithFlashes returns a copy of the response only consuming the flashes scoped
+// by keys, for instance a page fragment which must not consume the flashes of
+// the main page. Unscoped flashes are consumed when keys contains "".
+func (r Response) WithFlashes(keys ...string) Response {
+	r.FlashKeys = append(append([]string{}, r.FlashKeys...), keys...)
+
+	return r
 }
 
 func (r Response) Templates() []string {
@@ -17,3 +59,13 @@ func (r Response) Templates() []string {
 
 	return templates
 }
+
+// JSONError is the envelope written by Context.JSONErrorResponse.
+type JSONError struct {
+	Error JSONErrorDetails `json:"error"`
+}
+
+type JSONErrorDetails struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
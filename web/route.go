@@ -0,0 +1,146 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type Route struct {
+	server *Server
+	route  *mux.Route
+
+	writeTimeout    time.Duration
+	hasWriteTimeout bool
+	csrfExempt      bool
+}
+
+// Name registers the route under name so its URL can be built with Server.URL
+// or the url template func.
+func (r *Route) Name(name string) *Route {
+	r.route.Name(name)
+
+	return r
+}
+
+// WriteTimeout replaces the server write timeout for this route. A zero or
+// negative d removes the deadline, which is what long lived event streams need.
+func (r *Route) WriteTimeout(d time.Duration) *Route {
+	r.writeTimeout = d
+	r.hasWriteTimeout = true
+
+	return r
+}
+
+func (r *Route) withWriteTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.hasWriteTimeout {
+			var deadline time.Time
+			if r.writeTimeout > 0 {
+				deadline = time.Now().Add(r.writeTimeout)
+			}
+
+			if err := setWriteDeadline(req, deadline); err != nil {
+				r.server.logger.Errorf("can't override write timeout of %s: %v", req.URL.Path, err)
+			}
+		}
+
+		next(w, req)
+	}
+}
+
+// URL builds the path of the route registered under name. pairs are the route
+// variables as key/value pairs, for instance "id", "42".
+func (s *Server) URL(name string, pairs ...string) (string, error) {
+	route := s.router.Get(name)
+	if route == nil {
+		return "", fmt.Errorf("can't build url of route %s: route not found", name)
+	}
+
+	u, err := route.URL(pairs...)
+	if err != nil {
+		return "", fmt.Errorf("can't build url of route %s: %v", name, err)
+	}
+
+	return u.String(), nil
+}
+
+// urlFunc is the url template func. It accepts any value as route variables
+// so templates don't have to convert IDs to strings.
+func (s *Server) urlFunc(name string, pairs ...interface{}) (string, error) {
+	values := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		values = append(values, fmt.Sprint(pair))
+	}
+
+	return s.URL(name, values...)
+}
+
+// NotFound replaces the handler called when no route matches the request. By
+// default, the not found template is rendered with the error layout.
+func (s *Server) NotFound(h HandlerFunc) {
+	s.router.NotFoundHandler = s.wrapRequest(nil, nil, h)
+}
+
+// MethodNotAllowed replaces the handler called when a route matches the
+// request path but not its method. The Allow header is set before calling h.
+func (s *Server) MethodNotAllowed(h HandlerFunc) {
+	s.router.MethodNotAllowedHandler = s.wrapRequest(nil, nil, s.withAllowHeader(h))
+}
+
+func (s *Server) notFound(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+	return s.errorResponse(ctx, r, http.StatusNotFound, s.tmplCfg.NotFoundTemplate, "page not found")
+}
+
+func (s *Server) methodNotAllowed(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+	return s.errorResponse(ctx, r, http.StatusMethodNotAllowed, s.tmplCfg.MethodNotAllowedTemplate, "method not allowed")
+}
+
+func (s *Server) withAllowHeader(h HandlerFunc) HandlerFunc {
+	return func(ctx Context, w http.ResponseWriter, r *http.Request) Response {
+		w.Header().Set("Allow", strings.Join(s.allowedMethods(r), ", "))
+
+		return h(ctx, w, r)
+	}
+}
+
+func (s *Server) allowedMethods(r *http.Request) []string {
+	var allowed []string
+
+	methods := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	for _, method := range methods {
+		req := r.Clone(r.Context())
+		req.Method = method
+
+		var match mux.RouteMatch
+		if s.router.Match(req, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+// errorResponse renders template with the error layout, or a JSONError when
+// the client prefers JSON. It consumes no flash, so a missing favicon doesn't
+// take the flashes meant for the next page.
+func (s *Server) errorResponse(ctx Context, r *http.Request, httpCode int, template string, msg string) Response {
+	if negotiateFormat(r.Header.Get("Accept")) == ResponseFormatJSON {
+		return ctx.JSONErrorResponse(httpCode, "%s", msg).WithHeader("Vary", "Accept")
+	}
+
+	resp := Response{
+		HTTPCode:   httpCode,
+		LogMessage: msg,
+		Layout:     s.tmplCfg.ErrorLayout,
+		Template:   template,
+	}
+
+	return resp.WithHeader("Vary", "Accept").WithFlashes()
+}
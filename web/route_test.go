@@ -0,0 +1,185 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) Handle(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+	return ctx.JSONResponse(http.StatusOK, r.Method)
+}
+
+func TestServerHandle(t *testing.T) {
+	server := setupServer(t)
+	server.Handle("PUT", "/echo", echoHandler{})
+
+	body := serve(t, server, httptest.NewRequest("PUT", "/echo", nil), http.StatusOK)
+
+	testutils.AssertEqualString(t, `"PUT"`+"\n", body, "unexpected body")
+}
+
+func TestServerMethodHelpers(t *testing.T) {
+	echo := echoHandler{}.Handle
+
+	server := setupServer(t)
+	server.Get("/get", echo)
+	server.Post("/post", echo)
+	server.Put("/put", echo)
+	server.Patch("/patch", echo)
+	server.Delete("/delete", echo)
+	server.Match([]string{"GET", "POST"}, "/many", echo)
+
+	tcs := []struct {
+		method string
+		path   string
+	}{
+		{method: "GET", path: "/get"},
+		{method: "POST", path: "/post"},
+		{method: "PUT", path: "/put"},
+		{method: "PATCH", path: "/patch"},
+		{method: "DELETE", path: "/delete"},
+		{method: "GET", path: "/many"},
+		{method: "POST", path: "/many"},
+	}
+
+	for _, tc := range tcs {
+		body := serve(t, server, httptest.NewRequest(tc.method, tc.path, nil), http.StatusOK)
+		testutils.AssertEqualString(t, `"`+tc.method+`"`+"\n", body, "unexpected body for %s %s", tc.method, tc.path)
+	}
+}
+
+func TestServerMethodNotAllowed(t *testing.T) {
+	server := setupServer(t)
+	server.Get("/users", echoHandler{}.Handle)
+	server.Match([]string{"PUT", "DELETE"}, "/users", echoHandler{}.Handle)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/users", nil))
+
+	testutils.AssertEqualInt(t, http.StatusMethodNotAllowed, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "GET, PUT, DELETE", w.Header().Get("Allow"), "unexpected allowed methods")
+	testutils.AssertEqualString(t, "Accept", w.Header().Get("Vary"), "unexpected vary header")
+	testutils.AssertNotEmptyString(t, w.Header().Get("Trace-ID"), "expected a trace id")
+	testutils.AssertEqualString(t, `<html><body class="error">method not allowed</body></html>`, strings.TrimSpace(w.Body.String()), "unexpected body")
+}
+
+func TestServerMethodNotAllowedAsJSON(t *testing.T) {
+	server := setupServer(t)
+	server.EnableCSRFProtection()
+	server.Group("/api").Get("/users", echoHandler{}.Handle)
+
+	r := httptest.NewRequest("POST", "/api/users", nil)
+	r.Header.Set("Accept", "application/json")
+
+	body := serve(t, server, r, http.StatusMethodNotAllowed)
+
+	testutils.AssertEqualString(t, `{"error":{"status":405,"message":"method not allowed"}}`+"\n", body, "unexpected body")
+}
+
+func TestServerNamedRoutes(t *testing.T) {
+	server := setupServer(t)
+	server.Get("/users/{id}", echoHandler{}.Handle).Name("user")
+	server.Group("/admin").Get("/users/{id}/edit", echoHandler{}.Handle).Name("admin-user")
+	server.Get("/links/{id}", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/links.html", map[string]interface{}{"ID": 42})
+	})
+
+	url, err := server.URL("user", "id", "42")
+	testutils.RequireNoError(t, err, "can't build user url")
+	testutils.AssertEqualString(t, "/users/42", url, "unexpected user url")
+
+	url, err = server.URL("admin-user", "id", "42")
+	testutils.RequireNoError(t, err, "can't build admin user url")
+	testutils.AssertEqualString(t, "/admin/users/42/edit", url, "unexpected admin user url")
+
+	_, err = server.URL("unknown")
+	testutils.AssertErrorContains(t, "route not found", err, "expected unknown route to fail")
+
+	_, err = server.URL("user")
+	testutils.AssertErrorContains(t, "can't build url of route user", err, "expected missing variable to fail")
+
+	body := serve(t, server, httptest.NewRequest("GET", "/links/42", nil), http.StatusOK)
+	testutils.AssertContainsString(t, `<a href="/users/42">profile</a>`, body, "expected url template func to build the route path")
+}
+
+func TestServerNotFound(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), testSessionStore())
+	server.Group("/admin").Get("/users", echoHandler{}.Handle)
+
+	for _, target := range []string{"/unknown", "/admin/unknown"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+
+		testutils.AssertEqualInt(t, http.StatusNotFound, w.Code, "unexpected http code for %s", target)
+		testutils.AssertNotEmptyString(t, w.Header().Get("Trace-ID"), "expected a trace id for %s", target)
+		testutils.AssertEqualString(t, "Accept", w.Header().Get("Vary"), "unexpected vary header for %s", target)
+		testutils.AssertEqualString(t, `<html><body class="error">not found</body></html>`, strings.TrimSpace(w.Body.String()), "unexpected body for %s", target)
+	}
+	closer()
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 2, len(lines), "unexpected number of log lines")
+	for i, target := range []string{"/unknown", "/admin/unknown"} {
+		testutils.AssertEqualString(t, "page not found", lines[i]["msg"].(string), "unexpected log message")
+		testutils.AssertEqualString(t, target, lines[i]["http.target"].(string), "unexpected logged target")
+		testutils.AssertEqualFloat64(t, 404, lines[i]["http.status_code"].(float64), "unexpected logged status code")
+		testutils.AssertNotEmptyString(t, lines[i]["trace-id"].(string), "expected a logged trace id")
+	}
+}
+
+func TestServerCustomNotFound(t *testing.T) {
+	server := setupServer(t)
+	server.NotFound(func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONErrorResponse(http.StatusNotFound, "nothing at %s", r.URL.Path)
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/unknown", nil), http.StatusNotFound)
+
+	testutils.AssertEqualString(t, `{"error":{"status":404,"message":"nothing at /unknown"}}`+"\n", body, "unexpected body")
+}
+
+func TestServerCustomMethodNotAllowed(t *testing.T) {
+	server := setupServer(t)
+	server.Get("/users", echoHandler{}.Handle)
+	server.MethodNotAllowed(func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONErrorResponse(http.StatusMethodNotAllowed, "can't %s", r.Method)
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("DELETE", "/users", nil))
+
+	testutils.AssertEqualInt(t, http.StatusMethodNotAllowed, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "GET", w.Header().Get("Allow"), "unexpected allowed methods")
+	testutils.AssertEqualString(t, `{"error":{"status":405,"message":"can't DELETE"}}`+"\n", w.Body.String(), "unexpected body")
+}
+
+func TestServerNotFoundKeepsFlashes(t *testing.T) {
+	server := setupServer(t)
+	server.Post("/save", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewFlashMessageSuccess("saved"))
+		return ctx.Redirect(w, http.StatusFound, "/")
+	})
+	server.Get("/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+	})
+
+	cookies := serveWithCookies(t, server, httptest.NewRequest("POST", "/save", nil), nil)
+
+	notFound := httptest.NewRecorder()
+	server.ServeHTTP(notFound, withCookies(httptest.NewRequest("GET", "/favicon.ico", nil), cookies))
+	testutils.AssertEqualInt(t, http.StatusNotFound, notFound.Code, "unexpected http code")
+	cookies = mergeCookies(cookies, notFound.Result().Cookies())
+
+	page := httptest.NewRecorder()
+	server.ServeHTTP(page, withCookies(httptest.NewRequest("GET", "/", nil), cookies))
+	testutils.AssertContainsString(t, `<p class="flash-success">saved</p>`, page.Body.String(), "expected flash to be kept for the page")
+}
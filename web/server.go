@@ -3,14 +3,17 @@ package web
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
+	"html/template"
+	"io/fs"
+	"net"
 	"net/http"
-	"path"
+	"os"
+	"runtime/debug"
 	"strings"
-	"text/template"
-	"time"
+	"sync"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 	"github.com/lonepeon/golib/logger"
@@ -18,7 +21,32 @@ import (
 
 type TmplResponse struct {
 	Flashes []interface{}
-	Data    interface{}
+	// ScopedFlashes holds the flashes consumed with Response.WithFlashes, by key.
+	ScopedFlashes map[string][]interface{}
+	Data          interface{}
+	CSRFToken     string
+	Form          Form
+	Locale        string
+}
+
+// FieldFlashes returns the unscoped flashes about the form field, so they can
+// be rendered next to it.
+func (t TmplResponse) FieldFlashes(field string) []interface{} {
+	var flashes []interface{}
+	for _, flash := range t.Flashes {
+		switch f := flash.(type) {
+		case *FlashMessage:
+			if f.Field == field {
+				flashes = append(flashes, f)
+			}
+		case FlashMessage:
+			if f.Field == field {
+				flashes = append(flashes, f)
+			}
+		}
+	}
+
+	return flashes
 }
 
 type TmplConfiguration struct {
@@ -27,131 +55,430 @@ type TmplConfiguration struct {
 	ErrorLayout                 string
 	RedirectionTemplate         string
 	NotFoundTemplate            string
+	MethodNotAllowedTemplate    string
 	InternalServerErrorTemplate string
 	UnauthorizedTemplate        string
+	CSRFFailureTemplate         string
 }
 
 type Server struct {
-	logger       *logger.Logger
-	tmplCfg      TmplConfiguration
-	router       *mux.Router
-	server       http.Server
-	sessionStore sessions.Store
-	tmplFuncs    template.FuncMap
+	logger         *logger.Logger
+	options        ServerOptions
+	tmplCfg        TmplConfiguration
+	router         *mux.Router
+	server         http.Server
+	redirectServer *http.Server
+	redirectMutex  sync.Mutex
+	sessionStore   sessions.Store
+	tmplFuncs      template.FuncMap
+	tmplCache      *templateCache
+	tmplReloadFS   fs.FS
+
+	debug           bool
+	csrfEnabled     bool
+	assets          *assetManifest
+	root            *Group
+	httpMiddlewares []HTTPMiddleware
+	accessLog       *accessLog
+	shuttingDown    chan struct{}
+	livenessChecks  []healthCheck
+	readinessChecks []healthCheck
+	catalog         *Catalog
+	shutdownOnce    sync.Once
 }
 
+// NewServer creates a server using the default options. Use
+// NewServerWithOptions to configure and validate them. Error pages are
+// rendered without layout when tmplCfg has no ErrorLayout.
 func NewServer(log *logger.Logger, tmplCfg TmplConfiguration, sessionStore sessions.Store) *Server {
-	return &Server{
+	return newServer(log, sessionStore, ServerOptions{Templates: tmplCfg}.withDefaults())
+}
+
+// NewServerWithOptions creates a server after filling the missing options with
+// their defaults. It returns an ErrInvalidServerOptions describing every
+// invalid option.
+func NewServerWithOptions(log *logger.Logger, sessionStore sessions.Store, opts ServerOptions) (*Server, error) {
+	opts = opts.withDefaults()
+	if opts.Templates.ErrorLayout == "" {
+		opts.Templates.ErrorLayout = opts.Templates.Layout
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return newServer(log, sessionStore, opts), nil
+}
+
+func newServer(log *logger.Logger, sessionStore sessions.Store, opts ServerOptions) *Server {
+	s := &Server{
 		logger:  log,
-		tmplCfg: tmplCfg,
+		options: opts,
+		tmplCfg: opts.Templates,
 		router:  mux.NewRouter(),
 		server: http.Server{
-			ReadTimeout:       30 * time.Second,
-			ReadHeaderTimeout: 10 * time.Second,
-			WriteTimeout:      45 * time.Second,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			MaxHeaderBytes:    opts.MaxHeaderBytes,
+			TLSConfig:         opts.TLSConfig,
+			ConnContext:       withConn,
 		},
 		sessionStore: sessionStore,
+		tmplFuncs:    defaultTemplateFuncs(),
+		tmplCache:    newTemplateCache(),
+		accessLog:    newAccessLog(opts.AccessLog),
+		shuttingDown: make(chan struct{}),
 	}
+	s.root = &Group{server: s, router: s.router}
+	s.server.Handler = s
+	s.router.NotFoundHandler = s.wrapRequest(nil, nil, s.notFound)
+	s.router.MethodNotAllowedHandler = s.wrapRequest(nil, nil, s.withAllowHeader(s.methodNotAllowed))
+	s.tmplFuncs["url"] = s.urlFunc
+
+	return s
+}
+
+// AuthenticationSessionStore returns a store keeping the authenticated user in
+// the server session store under the configured auth cookie name.
+func (s *Server) AuthenticationSessionStore() CurrentAuthenticatedUserSessionStore {
+	return CurrentAuthenticatedUserSessionStore{store: s.sessionStore, name: s.options.AuthCookieName}
 }
 
 func (s *Server) ListenAndServe(addr string) error {
 	s.server.Addr = addr
-	s.server.Handler = s.router
 	return s.server.ListenAndServe()
 }
 
+// Serve accepts connections on l, for instance a unix socket or a socket
+// inherited through systemd activation.
+func (s *Server) Serve(l net.Listener) error {
+	return s.server.Serve(l)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := newResponseWriter(w)
+	defer s.recoverHTTPPanic(rw, r)
+
+	var handler http.Handler = s.router
+	for i := len(s.httpMiddlewares) - 1; i >= 0; i-- {
+		handler = s.httpMiddlewares[i](handler)
+	}
+
+	handler.ServeHTTP(rw, r)
+}
+
+// Use appends middlewares wrapping every HandlerFunc registered on the server.
+func (s *Server) Use(mws ...Middleware) {
+	s.root.Use(mws...)
+}
+
+// UseHTTP appends net/http middlewares wrapping every request received by the
+// server, including the ones not matching any route.
+func (s *Server) UseHTTP(mws ...HTTPMiddleware) {
+	s.httpMiddlewares = append(s.httpMiddlewares, mws...)
+}
+
+// Group creates a group of routes served under prefix with its own middlewares.
+func (s *Server) Group(prefix string) *Group {
+	return s.root.Group(prefix)
+}
+
+// AddTemplateFuncs registers funcs on top of the built-in trustedHTML and
+// trustedURL helpers. Templates are rendered with html/template so any value
+// not explicitly trusted is escaped according to its context.
 func (s *Server) AddTemplateFuncs(funcs template.FuncMap) {
-	s.tmplFuncs = funcs
+	for name, fn := range funcs {
+		s.tmplFuncs[name] = fn
+	}
+
+	s.tmplCache.reset()
+}
+
+// EnableTemplatesReload makes the server read templates from dir on every
+// request instead of the embedded FS, so changes show up without a restart.
+// dir must have the same layout as the embedded FS. It's meant for development only.
+func (s *Server) EnableTemplatesReload(dir string) {
+	s.tmplReloadFS = os.DirFS(dir)
+}
+
+// EnableDebug exposes the panic value and stack to the internal server error
+// template as .Data.Panic and .Data.Stack. It must never be enabled in production.
+func (s *Server) EnableDebug() {
+	s.debug = true
 }
 
+// ValidateTemplates parses the configured error and redirection templates as
+// well as the given page templates with the main layout. It returns an error on
+// the first missing or invalid file so broken templates are caught at startup.
+// Successfully parsed templates are cached and reused when serving requests.
+func (s *Server) ValidateTemplates(pages ...string) error {
+	var responses []Response
+	if s.tmplCfg.RedirectionTemplate != "" {
+		responses = append(responses, Response{Template: s.tmplCfg.RedirectionTemplate})
+	}
+
+	errorTemplates := []string{
+		s.tmplCfg.NotFoundTemplate,
+		s.tmplCfg.MethodNotAllowedTemplate,
+		s.tmplCfg.InternalServerErrorTemplate,
+		s.tmplCfg.UnauthorizedTemplate,
+		s.tmplCfg.CSRFFailureTemplate,
+	}
+	for _, tmpl := range errorTemplates {
+		if tmpl != "" {
+			responses = append(responses, Response{Layout: s.tmplCfg.ErrorLayout, Template: tmpl})
+		}
+	}
+
+	for _, page := range pages {
+		responses = append(responses, Response{Layout: s.tmplCfg.Layout, Template: page})
+	}
+
+	for _, resp := range responses {
+		if _, err := s.templates(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown closes the event streams, which would otherwise keep their
+// connection active, and waits for the other requests to finish. Both the
+// https redirection server and the main server are always shut down; the
+// first error is returned.
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	s.shutdownOnce.Do(func() { close(s.shuttingDown) })
+
+	s.redirectMutex.Lock()
+	redirectServer := s.redirectServer
+	s.redirectMutex.Unlock()
+
+	var redirectErr error
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			redirectErr = fmt.Errorf("can't shutdown https redirection server: %w", err)
+		}
+	}
+
+	err := s.server.Shutdown(ctx)
+	if redirectErr != nil {
+		return redirectErr
+	}
+
+	return err
 }
 
-func (s *Server) HandleFunc(method string, urlpath string, h HandlerFunc) {
-	handler := s.wrapRequest(method, urlpath, h)
-	s.router.HandleFunc(urlpath, handler).Methods(method)
+func (s *Server) HandleFunc(method string, urlpath string, h HandlerFunc) *Route {
+	return s.root.HandleFunc(method, urlpath, h)
 }
 
-func (s *Server) Handle(method string, urlpath string, h Handler) {
-	s.HandleFunc(urlpath, urlpath, h.Handle)
+func (s *Server) Handle(method string, urlpath string, h Handler) *Route {
+	return s.root.Handle(method, urlpath, h)
 }
 
-func (s *Server) wrapRequest(method string, urlpath string, h HandlerFunc) http.HandlerFunc {
+func (s *Server) Match(methods []string, urlpath string, h HandlerFunc) *Route {
+	return s.root.Match(methods, urlpath, h)
+}
+
+func (s *Server) Get(urlpath string, h HandlerFunc) *Route {
+	return s.root.Get(urlpath, h)
+}
+
+func (s *Server) Post(urlpath string, h HandlerFunc) *Route {
+	return s.root.Post(urlpath, h)
+}
+
+func (s *Server) Put(urlpath string, h HandlerFunc) *Route {
+	return s.root.Put(urlpath, h)
+}
+
+func (s *Server) Patch(urlpath string, h HandlerFunc) *Route {
+	return s.root.Patch(urlpath, h)
+}
+
+func (s *Server) Delete(urlpath string, h HandlerFunc) *Route {
+	return s.root.Delete(urlpath, h)
+}
+
+// wrapRequest turns h into an http.HandlerFunc creating the Context, writing
+// the response and logging the request. g and route are nil for the handlers
+// which don't belong to any group, such as the method not allowed one.
+func (s *Server) wrapRequest(g *Group, route *Route, h HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		traceID := uuid.NewString()
-		urlScheme := "http"
-		if r.TLS != nil {
-			urlScheme = "https"
-		}
-		reqLogger := s.logger.WithFields(
-			logger.String("trace-id", traceID),
-			logger.String("http.method", method),
-			logger.String("http.url", r.URL.String()),
-			logger.String("http.target", r.URL.RequestURI()),
-			logger.String("http.host", r.Host),
-			logger.String("http.scheme", urlScheme),
-			logger.String("http.flavor", fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)),
-			logger.String("http.user_agent", r.UserAgent()),
-		)
-
-		session, err := s.sessionStore.Get(r, "trax")
+		rw := newResponseWriter(w)
+		traceID := requestTraceID(r)
+		reqLogger := s.requestLogger(r, traceID)
+		if s.options.MaxBodyBytes != NoMaxBodyBytes {
+			r.Body = http.MaxBytesReader(rw, r.Body, s.options.MaxBodyBytes)
+		}
+
+		session, err := s.sessionStore.Get(r, s.options.SessionCookieName)
 		if err != nil {
-			reqLogger.
-				WithFields(logger.Int("http.status_code", http.StatusInternalServerError)).
-				Errorf("can't get session store: %v", err)
-			http.Error(w, "something wrong happened", http.StatusInternalServerError)
+			_, msg := s.write500(rw, fmt.Errorf("can't get session store: %v", err))
+			s.logRequest(reqLogger, r, rw, msg)
 			return
 		}
 
 		ctx := ContextImpl{
-			Context:           r.Context(),
+			Context:           logger.ContextWithTraceID(r.Context(), traceID),
 			tmplConfiguration: s.tmplCfg,
 			session:           session,
+			logger:            reqLogger,
+			traceID:           traceID,
+			catalog:           s.catalog,
+			locale:            s.negotiateLocale(r),
 		}
 
-		w.Header().Add("Trace-ID", traceID)
+		rw.Header().Add("Trace-ID", traceID)
 
-		httpCode, msg := s.writeResponse(&ctx, w, r, session, h(&ctx, w, r))
+		if s.csrfEnabled {
+			ctx.csrfToken, err = csrfToken(session)
+			if err != nil {
+				_, msg := s.write500(rw, fmt.Errorf("can't get csrf token: %v", err))
+				s.logRequest(reqLogger, r, rw, msg)
+				return
+			}
 
-		reqLogger = reqLogger.WithFields(logger.Int("http.status_code", httpCode))
-		logFn := reqLogger.Info
-		if httpCode >= http.StatusInternalServerError {
-			logFn = reqLogger.Error
+			if !isCSRFSafeMethod(r.Method) && !g.isCSRFExempt() && !route.isCSRFExempt() && !validCSRFToken(r, ctx.csrfToken) {
+				h = func(_ Context, w http.ResponseWriter, r *http.Request) Response {
+					return s.csrfFailureResponse(&ctx, w, r)
+				}
+			}
 		}
 
-		logFn(msg)
+		resp, stack := s.callHandler(&ctx, rw, r, h)
+		if stack != "" {
+			reqLogger = reqLogger.WithFields(logger.String("exception.stacktrace", stack))
+		}
+
+		if !rw.headersSent && timedOut(r) {
+			original := resp.LogMessage
+			resp = s.errorResponse(&ctx, r, http.StatusServiceUnavailable, s.tmplCfg.InternalServerErrorTemplate, "request timed out")
+			resp.LogMessage = s.wrapLogMessage(original, "request timed out").Error()
+		}
+
+		msg := resp.LogMessage
+		if !rw.headersSent {
+			_, msg = s.writeResponse(&ctx, rw, r, session, resp)
+		}
+
+		s.logRequest(reqLogger, r, rw, msg)
+	}
+}
+
+func (s *Server) requestLogger(r *http.Request, traceID string) *logger.Logger {
+	urlScheme := "http"
+	if r.TLS != nil {
+		urlScheme = "https"
+	}
+
+	return s.logger.WithFields(
+		logger.String("trace-id", traceID),
+		logger.String("http.method", r.Method),
+		logger.String("http.url", r.URL.String()),
+		logger.String("http.target", r.URL.RequestURI()),
+		logger.String("http.host", r.Host),
+		logger.String("http.scheme", urlScheme),
+		logger.String("http.flavor", fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)),
+		logger.String("http.user_agent", r.UserAgent()),
+	)
+}
+
+// callHandler runs h and turns any panic into an internal server error
+// response. The stack of the panic is returned so it can be logged, and is only
+// exposed to the error template when the debug mode is enabled.
+func (s *Server) callHandler(ctx *ContextImpl, w http.ResponseWriter, r *http.Request, h HandlerFunc) (resp Response, stack string) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+
+		if rec == http.ErrAbortHandler {
+			panic(rec)
+		}
+
+		stack = string(debug.Stack())
+		resp = ctx.InternalServerErrorResponse("panic recovered: %v", rec)
+		if s.debug {
+			resp.Data = map[string]interface{}{"Panic": fmt.Sprint(rec), "Stack": stack}
+		}
+	}()
+
+	return h(ctx, w, r), ""
+}
+
+// recoverHTTPPanic catches the panics callHandler can't see, raised by the
+// HTTP middlewares or the handlers not built by wrapRequest such as the assets
+// one. They are logged with their stack and answered with a plain internal
+// server error when the headers aren't sent yet.
+func (s *Server) recoverHTTPPanic(w *responseWriter, r *http.Request) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+
+	if rec == http.ErrAbortHandler {
+		panic(rec)
+	}
+
+	traceID := w.Header().Get("Trace-ID")
+	if traceID == "" {
+		traceID = requestTraceID(r)
+		w.Header().Add("Trace-ID", traceID)
 	}
+
+	reqLogger := s.requestLogger(r, traceID).WithFields(logger.String("exception.stacktrace", string(debug.Stack())))
+	_, msg := s.write500(w, fmt.Errorf("panic recovered: %v", rec))
+	s.logRequest(reqLogger, r, w, msg)
 }
 
 func (s *Server) writeResponse(ctx *ContextImpl, w http.ResponseWriter, r *http.Request, session *sessions.Session, resp Response) (int, string) {
-	tmplName := path.Base(resp.Layout)
-	if tmplName == "." {
-		tmplName = path.Base(resp.Template)
+	for key, values := range resp.Headers {
+		w.Header().Del(key)
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
 	}
 
-	tmpl, err := template.
-		New(tmplName).
-		Funcs(s.tmplFuncs).
-		ParseFS(ctx.tmplConfiguration.FS, resp.Templates()...)
+	switch resp.Format {
+	case ResponseFormatJSON:
+		return s.writeJSONResponse(w, r, session, resp)
+	case ResponseFormatEventStream:
+		return s.writeEventStreamResponse(w, r, session, resp)
+	}
 
-	if err != nil {
-		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't parse templates from %s: %v", strings.Join(resp.Templates(), ","), err))
+	return s.writeHTMLResponse(ctx, w, r, session, resp)
+}
 
+func (s *Server) writeHTMLResponse(ctx *ContextImpl, w http.ResponseWriter, r *http.Request, session *sessions.Session, resp Response) (int, string) {
+	tmpl, err := s.templates(resp)
+	if err != nil {
+		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "%v", err))
 	}
 
 	tmplResponse := TmplResponse{
-		Data: resp.Data,
+		Data:      resp.Data,
+		CSRFToken: ctx.csrfToken,
+		Form:      ctx.form,
+		Locale:    ctx.locale,
 	}
 
-	if resp.HTTPCode < 300 || resp.HTTPCode >= 400 {
-		tmplResponse.Flashes = session.Flashes()
+	if session != nil && (resp.HTTPCode < 300 || resp.HTTPCode >= 400) {
+		s.consumeFlashes(ctx, session, resp, &tmplResponse)
 	}
 
-	if err := session.Save(r, w); err != nil {
+	if err := saveSession(session, r, w); err != nil {
 		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't save session: %v", err))
 	}
 
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+
 	w.WriteHeader(resp.HTTPCode)
 	if err := tmpl.Execute(w, tmplResponse); err != nil {
 		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't execute templates from %s: %v", strings.Join(resp.Templates(), ","), err))
@@ -160,7 +487,88 @@ func (s *Server) writeResponse(ctx *ContextImpl, w http.ResponseWriter, r *http.
 	return resp.HTTPCode, resp.LogMessage
 }
 
+// writeJSONResponse encodes the response data before writing anything so
+// encoding failures can still be reported as a proper 500. Flashes are left in
+// the session for the next HTML page.
+func (s *Server) writeJSONResponse(w http.ResponseWriter, r *http.Request, session *sessions.Session, resp Response) (int, string) {
+	body, err := json.Marshal(resp.Data)
+	if err != nil {
+		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't encode json response: %v", err))
+	}
+
+	if err := saveSession(session, r, w); err != nil {
+		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't save session: %v", err))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(resp.HTTPCode)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		return resp.HTTPCode, s.wrapLogMessage(resp.LogMessage, "can't write json response: %v", err).Error()
+	}
+
+	return resp.HTTPCode, resp.LogMessage
+}
+
+// saveSession writes the session cookie, unless the request is served without
+// session such as the health endpoints.
+func saveSession(session *sessions.Session, r *http.Request, w http.ResponseWriter) error {
+	if session == nil {
+		return nil
+	}
+
+	return session.Save(r, w)
+}
+
+func (s *Server) templates(resp Response) (*template.Template, error) {
+	files := resp.Templates()
+	if s.tmplReloadFS != nil {
+		return parseTemplates(s.tmplReloadFS, s.tmplFuncs, files)
+	}
+
+	key := strings.Join(files, ",")
+	if tmpl, ok := s.tmplCache.get(key); ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := parseTemplates(s.tmplCfg.FS, s.tmplFuncs, files)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tmplCache.set(key, tmpl)
+
+	return tmpl, nil
+}
+
+// consumeFlashes moves the flashes asked by resp from the session to the
+// template data, translated in the request locale.
+func (s *Server) consumeFlashes(ctx *ContextImpl, session *sessions.Session, resp Response, tmplResponse *TmplResponse) {
+	keys := resp.FlashKeys
+	if keys == nil {
+		keys = []string{""}
+	}
+
+	for _, key := range keys {
+		flashes := translateFlashes(s.catalog, ctx.locale, consumeFlashes(session, key))
+		if key == "" {
+			tmplResponse.Flashes = flashes
+			continue
+		}
+
+		if tmplResponse.ScopedFlashes == nil {
+			tmplResponse.ScopedFlashes = make(map[string][]interface{})
+		}
+		tmplResponse.ScopedFlashes[key] = flashes
+	}
+}
+
+// write500 replaces the response by a generic error, unless the headers have
+// already been sent, in which case the error is only logged.
 func (s *Server) write500(w http.ResponseWriter, err error) (int, string) {
+	if rw, ok := w.(*responseWriter); ok && rw.headersSent {
+		return rw.code, err.Error()
+	}
+
 	http.Error(w, "something wrong happened", http.StatusInternalServerError)
 	return http.StatusInternalServerError, err.Error()
 }
@@ -0,0 +1,322 @@
+package web_test
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+//go:embed testdata/templates
+var templatesFS embed.FS
+
+func TestServerEscapesData(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", map[string]interface{}{
+			"Title": `<script>alert("xss")</script>`,
+			"Link":  `javascript:alert("xss")`,
+		})
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.AssertContainsString(t, "&lt;script&gt;alert(&#34;xss&#34;)&lt;/script&gt;", body, "expected title to be escaped")
+	testutils.AssertContainsString(t, `href="#ZgotmplZ"`, body, "expected unsafe url to be filtered")
+	testutils.AssertEqualBool(t, false, strings.Contains(body, "<script>"), "expected no script tag in body:\n%s", body)
+}
+
+func TestServerEscapesFlashes(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewFlashMessageError("unknown user %s", `<img src=x onerror="alert(1)">`))
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", map[string]interface{}{"Title": "home"})
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.AssertContainsString(t, `<p class="flash-error">unknown user &lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>`, body, "expected flash to be escaped")
+}
+
+func TestServerRendersTrustedValues(t *testing.T) {
+	server := setupServer(t)
+	server.AddTemplateFuncs(map[string]interface{}{
+		"shout": strings.ToUpper,
+	})
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.Response(http.StatusOK, "testdata/templates/trusted.html", map[string]interface{}{
+			"Title": "<b>home</b>",
+			"Body":  "<em>hello</em>",
+			"Link":  "mailto:hello@example.com",
+		})
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+
+	testutils.AssertContainsString(t, "<div><em>hello</em></div>", body, "expected trusted html to be rendered as is")
+	testutils.AssertContainsString(t, `href="mailto:hello@example.com"`, body, "expected trusted url to be rendered as is")
+	testutils.AssertContainsString(t, "<p>&lt;B&gt;HOME&lt;/B&gt;</p>", body, "expected custom func output to be escaped")
+}
+
+func TestServerValidateTemplatesSuccess(t *testing.T) {
+	server := setupServer(t)
+
+	err := server.ValidateTemplates("testdata/templates/page.html")
+
+	testutils.AssertNoError(t, err, "expected templates to be valid")
+}
+
+func TestServerValidateTemplatesErrors(t *testing.T) {
+	tcs := map[string]struct {
+		configure func(*web.TmplConfiguration)
+		page      string
+		wantError string
+	}{
+		"missingPage": {
+			page:      "testdata/templates/unknown.html",
+			wantError: "testdata/templates/unknown.html",
+		},
+		"invalidPage": {
+			page:      "testdata/templates/broken.html",
+			wantError: "testdata/templates/broken.html",
+		},
+		"undefinedFunc": {
+			page:      "testdata/templates/trusted.html",
+			wantError: `function "shout" not defined`,
+		},
+		"missingErrorTemplate": {
+			configure: func(cfg *web.TmplConfiguration) { cfg.NotFoundTemplate = "testdata/templates/404.html" },
+			wantError: "testdata/templates/404.html",
+		},
+		"missingErrorLayout": {
+			configure: func(cfg *web.TmplConfiguration) { cfg.ErrorLayout = "testdata/templates/unknown_layout.html" },
+			wantError: "testdata/templates/unknown_layout.html",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			cfg := testTmplConfiguration()
+			if tc.configure != nil {
+				tc.configure(&cfg)
+			}
+			server := newTestServer(t, cfg)
+
+			var pages []string
+			if tc.page != "" {
+				pages = append(pages, tc.page)
+			}
+
+			err := server.ValidateTemplates(pages...)
+
+			testutils.AssertErrorContains(t, tc.wantError, err, "expected templates to be invalid")
+		})
+	}
+}
+
+func TestServerTemplatesReload(t *testing.T) {
+	dir := t.TempDir()
+	tmplDir := filepath.Join(dir, "testdata", "templates")
+	testutils.RequireNoError(t, os.MkdirAll(tmplDir, 0o755), "can't create template directory")
+
+	writeTemplate := func(content string) {
+		err := os.WriteFile(filepath.Join(tmplDir, "live.html"), []byte(content), 0o600)
+		testutils.RequireNoError(t, err, "can't write template")
+	}
+
+	server := setupServer(t)
+	server.EnableTemplatesReload(dir)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		resp := ctx.Response(http.StatusOK, "testdata/templates/live.html", nil)
+		resp.Layout = ""
+		return resp
+	})
+
+	writeTemplate("first version")
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+	testutils.AssertEqualString(t, "first version", body, "unexpected first rendering")
+
+	writeTemplate("second version")
+	body = serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusOK)
+	testutils.AssertEqualString(t, "second version", body, "expected template to be reloaded")
+}
+
+func TestServerJSONResponse(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/api/users", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		ctx.AddFlash(web.NewFlashMessageSuccess("kept for the next page"))
+		return ctx.JSONResponse(http.StatusCreated, map[string]interface{}{"name": "<john>"}).WithHeader("X-Total-Count", "1")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/users", nil))
+
+	testutils.AssertEqualInt(t, http.StatusCreated, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), "unexpected content type")
+	testutils.AssertEqualString(t, "1", w.Header().Get("X-Total-Count"), "unexpected custom header")
+	testutils.AssertNotEmptyString(t, w.Header().Get("Trace-ID"), "expected a trace id")
+	testutils.AssertEqualString(t, `{"name":"\u003cjohn\u003e"}`+"\n", w.Body.String(), "unexpected body")
+}
+
+func TestServerJSONErrorResponse(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/api/users/{id}", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONErrorResponse(http.StatusNotFound, "user %s not found", ctx.Vars(r)["id"])
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/api/users/42", nil), http.StatusNotFound)
+
+	testutils.AssertEqualString(t, `{"error":{"status":404,"message":"user 42 not found"}}`+"\n", body, "unexpected body")
+}
+
+func TestServerJSONResponseEncodingError(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/api/users", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, map[string]interface{}{"callback": func() {}})
+	})
+
+	serve(t, server, httptest.NewRequest("GET", "/api/users", nil), http.StatusInternalServerError)
+}
+
+func TestServerNegotiatedResponse(t *testing.T) {
+	tcs := map[string]struct {
+		accept          string
+		wantContentType string
+	}{
+		"noAcceptHeader":       {accept: "", wantContentType: "text/html; charset=utf-8"},
+		"browser":              {accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", wantContentType: "text/html; charset=utf-8"},
+		"anything":             {accept: "*/*", wantContentType: "text/html; charset=utf-8"},
+		"json":                 {accept: "application/json", wantContentType: "application/json; charset=utf-8"},
+		"jsonPreferred":        {accept: "text/html;q=0.5, application/json", wantContentType: "application/json; charset=utf-8"},
+		"htmlPreferred":        {accept: "application/json;q=0.5, text/html", wantContentType: "text/html; charset=utf-8"},
+		"specificOverWildcard": {accept: "text/html;q=0.1, */*", wantContentType: "application/json; charset=utf-8"},
+		"typeWildcard":         {accept: "application/*", wantContentType: "application/json; charset=utf-8"},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			server := setupServer(t)
+			server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				return ctx.NegotiatedResponse(r, http.StatusOK, "testdata/templates/page.html", map[string]interface{}{"Title": "home"})
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+
+			testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+			testutils.AssertEqualString(t, tc.wantContentType, w.Header().Get("Content-Type"), "unexpected content type")
+			testutils.AssertEqualString(t, "Accept", w.Header().Get("Vary"), "unexpected vary header")
+		})
+	}
+}
+
+func TestServerRecoversFromPanics(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	closer()
+
+	testutils.AssertEqualInt(t, http.StatusInternalServerError, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, `<html><body class="error">internal error</body></html>`, strings.TrimSpace(w.Body.String()), "unexpected body")
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualString(t, "panic recovered: boom", lines[0]["msg"].(string), "unexpected log message")
+	testutils.AssertEqualString(t, w.Header().Get("Trace-ID"), lines[0]["trace-id"].(string), "unexpected trace id")
+	testutils.AssertEqualFloat64(t, 500, lines[0]["http.status_code"].(float64), "unexpected status code")
+	testutils.AssertContainsString(t, "web_test.TestServerRecoversFromPanics", lines[0]["exception.stacktrace"].(string), "expected stack in logs")
+}
+
+func TestServerRecoversFromHTTPMiddlewarePanics(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+	server.UseHTTP(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	closer()
+
+	testutils.AssertEqualInt(t, http.StatusInternalServerError, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "something wrong happened", strings.TrimSpace(w.Body.String()), "unexpected body")
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualString(t, "panic recovered: boom", lines[0]["msg"].(string), "unexpected log message")
+	testutils.AssertEqualString(t, w.Header().Get("Trace-ID"), lines[0]["trace-id"].(string), "unexpected trace id")
+	testutils.AssertEqualFloat64(t, 500, lines[0]["http.status_code"].(float64), "unexpected status code")
+	testutils.AssertContainsString(t, "web_test.TestServerRecoversFromHTTPMiddlewarePanics", lines[0]["exception.stacktrace"].(string), "expected stack in logs")
+}
+
+func TestServerShowsPanicStackInDebugMode(t *testing.T) {
+	server := setupServer(t)
+	server.EnableDebug()
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		panic("boom")
+	})
+
+	body := serve(t, server, httptest.NewRequest("GET", "/", nil), http.StatusInternalServerError)
+
+	testutils.AssertContainsString(t, "<pre>boom\n", body, "expected panic value on page")
+	testutils.AssertContainsString(t, "web_test.TestServerShowsPanicStackInDebugMode", body, "expected stack on page")
+}
+
+func setupServer(t *testing.T) *web.Server {
+	return newTestServer(t, testTmplConfiguration())
+}
+
+func newTestServer(t *testing.T, tmplCfg web.TmplConfiguration) *web.Server {
+	log, _, closer := loggertest.NewFake(t)
+	t.Cleanup(closer)
+
+	return web.NewServer(log, tmplCfg, testSessionStore())
+}
+
+func testSessionStore() sessions.Store {
+	return sessions.NewCookieStore([]byte("secret-key"))
+}
+
+func testTmplConfiguration() web.TmplConfiguration {
+	return web.TmplConfiguration{
+		FS:                          templatesFS,
+		Layout:                      "testdata/templates/layout.html",
+		ErrorLayout:                 "testdata/templates/error.html",
+		RedirectionTemplate:         "testdata/templates/redirect.html",
+		NotFoundTemplate:            "testdata/templates/not_found.html",
+		MethodNotAllowedTemplate:    "testdata/templates/method_not_allowed.html",
+		InternalServerErrorTemplate: "testdata/templates/internal_error.html",
+		UnauthorizedTemplate:        "testdata/templates/unauthorized.html",
+		CSRFFailureTemplate:         "testdata/templates/csrf_failure.html",
+	}
+}
+
+func serve(t *testing.T, server *web.Server, r *http.Request, wantCode int) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+
+	testutils.AssertEqualInt(t, wantCode, w.Code, "unexpected http code")
+
+	return w.Body.String()
+}
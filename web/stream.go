@@ -0,0 +1,202 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+var ErrWriteDeadlineNotSupported = errors.New("write deadline not supported")
+
+// EventStreamFunc produces the events of a server-sent events response. It
+// must return once the stream is done, which happens when the client
+// disconnects or the server shuts down.
+type EventStreamFunc func(stream *EventStream) error
+
+// Event is a server-sent event. Only Data is required, the other fields are
+// left out when empty.
+type Event struct {
+	ID    string
+	Name  string
+	Data  string
+	Retry time.Duration
+}
+
+// EventStream writes events to the client. It is safe for concurrent use.
+type EventStream struct {
+	ctx     context.Context
+	l       sync.Mutex
+	w       io.Writer
+	flusher http.Flusher
+	err     error
+}
+
+// Done is closed when the client disconnects or the server shuts down.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes e and flushes it to the client. It returns an error once the
+// stream is done.
+func (s *EventStream) Send(e Event) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", singleLine(e.ID))
+	}
+
+	if e.Name != "" {
+		fmt.Fprintf(&buf, "event: %s\n", singleLine(e.Name))
+	}
+
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry.Milliseconds())
+	}
+
+	data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+
+	return s.write(buf.Bytes())
+}
+
+// SendJSON sends v encoded as JSON in an event called name.
+func (s *EventStream) SendJSON(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("can't encode event %s: %v", name, err)
+	}
+
+	return s.Send(Event{Name: name, Data: string(data)})
+}
+
+// heartbeat sends a comment, ignored by browsers, so proxies don't close an
+// idle connection.
+func (s *EventStream) heartbeat() error {
+	return s.write([]byte(": heartbeat\n\n"))
+}
+
+func (s *EventStream) write(b []byte) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		s.err = fmt.Errorf("event stream closed: %w", err)
+		return s.err
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		s.err = fmt.Errorf("can't write event: %w", err)
+		return s.err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+func singleLine(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// writeEventStreamResponse keeps the connection open until the stream func
+// returns. Heartbeats are sent in the background and stopped before returning
+// since nothing can be written once the handler is over.
+func (s *Server) writeEventStreamResponse(w http.ResponseWriter, r *http.Request, session *sessions.Session, resp Response) (int, string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't stream events: response writer doesn't support flushing"))
+	}
+
+	if err := session.Save(r, w); err != nil {
+		return s.write500(w, s.wrapLogMessage(resp.LogMessage, "can't save session: %v", err))
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.shuttingDown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	headers := w.Header()
+	headers.Set("Content-Type", "text/event-stream")
+	headers.Set("Cache-Control", "no-cache")
+	headers.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(resp.HTTPCode)
+	flusher.Flush()
+
+	stream := &EventStream{ctx: ctx, w: w, flusher: flusher}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(s.options.EventStreamHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := stream.heartbeat(); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	err := resp.eventStream(stream)
+	cancel()
+	wg.Wait()
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return resp.HTTPCode, s.wrapLogMessage(resp.LogMessage, "event stream failed: %v", err).Error()
+	}
+
+	if r.Context().Err() != nil {
+		return resp.HTTPCode, fmt.Sprintf("%s: client disconnected", resp.LogMessage)
+	}
+
+	return resp.HTTPCode, resp.LogMessage
+}
+
+type connContextKey struct{}
+
+// withConn stores the connection in the request context, through
+// http.Server.ConnContext, so a route can change its write deadline.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// setWriteDeadline sets the deadline on the connection of r. With HTTP/2 the
+// connection is shared by every stream of the client, so the deadline applies
+// to all of them.
+func setWriteDeadline(r *http.Request, deadline time.Time) error {
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return fmt.Errorf("%w: request not received by the server listener", ErrWriteDeadlineNotSupported)
+	}
+
+	return conn.SetWriteDeadline(deadline)
+}
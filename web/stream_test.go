@@ -0,0 +1,135 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestEventStreamResponse(t *testing.T) {
+	log, logs, closer := loggertest.NewFake(t)
+	server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+	server.HandleFunc("GET", "/events", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.EventStreamResponse(func(stream *web.EventStream) error {
+			if err := stream.Send(web.Event{ID: "1\n", Name: "progress", Data: "line 1\nline 2", Retry: 3 * time.Second}); err != nil {
+				return err
+			}
+
+			return stream.SendJSON("done", map[string]int{"percent": 100})
+		})
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	closer()
+
+	testutils.AssertEqualInt(t, http.StatusOK, w.Code, "unexpected http code")
+	testutils.AssertEqualString(t, "text/event-stream", w.Header().Get("Content-Type"), "unexpected content type")
+	testutils.AssertEqualString(t, "no-cache", w.Header().Get("Cache-Control"), "unexpected cache control")
+
+	want := "id: 1\nevent: progress\nretry: 3000\ndata: line 1\ndata: line 2\n\n" +
+		"event: done\ndata: {\"percent\":100}\n\n"
+	testutils.AssertEqualString(t, want, w.Body.String(), "unexpected body")
+
+	lines := logs.Lines()
+	testutils.RequireEqualInt(t, 1, len(lines), "unexpected number of log lines")
+	testutils.AssertEqualString(t, "event stream closed", lines[0]["msg"].(string), "unexpected log message")
+	testutils.AssertEqualFloat64(t, float64(len(want)), lines[0]["http.response_content_length"].(float64), "unexpected content length")
+}
+
+func TestEventStreamHeartbeatAndDisconnection(t *testing.T) {
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates:            testTmplConfiguration(),
+		EventStreamHeartbeat: 10 * time.Millisecond,
+	})
+
+	closed := make(chan error, 1)
+	server.HandleFunc("GET", "/events", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.EventStreamResponse(func(stream *web.EventStream) error {
+			<-stream.Done()
+			closed <- stream.Send(web.Event{Data: "too late"})
+			return nil
+		})
+	})
+
+	addr := serveEventStreams(t, server)
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "GET", "http://"+addr+"/events", nil)
+	testutils.RequireNoError(t, err, "can't create request")
+	resp, err := http.DefaultClient.Do(req)
+	testutils.RequireNoError(t, err, "can't open event stream")
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	testutils.RequireNoError(t, err, "can't read event stream")
+	testutils.AssertEqualString(t, ": heartbeat\n", line, "expected a heartbeat")
+
+	cancel()
+
+	select {
+	case err := <-closed:
+		testutils.AssertErrorIs(t, context.Canceled, err, "expected send to fail after disconnection")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected stream to be done after client disconnection")
+	}
+}
+
+func TestEventStreamIsClosedOnShutdown(t *testing.T) {
+	server := setupServer(t)
+	server.HandleFunc("GET", "/events", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.EventStreamResponse(func(stream *web.EventStream) error {
+			<-stream.Done()
+			return nil
+		})
+	})
+
+	addr := serveEventStreams(t, server)
+	resp, err := http.Get("http://" + addr + "/events")
+	testutils.RequireNoError(t, err, "can't open event stream")
+	defer resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	testutils.AssertNoError(t, server.Shutdown(ctx), "expected shutdown not to wait for the stream")
+}
+
+func TestRouteWriteTimeout(t *testing.T) {
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates:    testTmplConfiguration(),
+		WriteTimeout: 50 * time.Millisecond,
+	})
+	server.HandleFunc("GET", "/events", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.EventStreamResponse(func(stream *web.EventStream) error {
+			time.Sleep(200 * time.Millisecond)
+			return stream.Send(web.Event{Data: "still there"})
+		})
+	}).WriteTimeout(0)
+
+	addr := serveEventStreams(t, server)
+	resp, err := http.Get("http://" + addr + "/events")
+	testutils.RequireNoError(t, err, "can't open event stream")
+	defer resp.Body.Close()
+
+	body := readBody(t, resp)
+	testutils.AssertEqualBool(t, true, strings.Contains(body, "data: still there\n"), "expected event after server write timeout, got %q", body)
+}
+
+func serveEventStreams(t *testing.T, server *web.Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.RequireNoError(t, err, "can't listen")
+	startServer(t, server, func() error { return server.Serve(l) })
+
+	return l.Addr().String()
+}
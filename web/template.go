@@ -0,0 +1,76 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// TrustedHTML marks s as safe HTML which is written to the page as is. It must
+// only be used with content coming from the application, never from users.
+func TrustedHTML(s string) template.HTML {
+	return template.HTML(s)
+}
+
+// TrustedURL marks s as a safe URL so its scheme isn't filtered out when
+// rendered in an attribute. It must only be used with application-controlled URLs.
+func TrustedURL(s string) template.URL {
+	return template.URL(s)
+}
+
+func defaultTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"trustedHTML": TrustedHTML,
+		"trustedURL":  TrustedURL,
+		"t": func(locale string, key string, params ...interface{}) string {
+			return translate(nil, locale, key, translationParams(params))
+		},
+	}
+}
+
+// templateCache keeps parsed templates per layout/template pair so files are
+// read and parsed only once during the server lifetime.
+type templateCache struct {
+	mutex     sync.RWMutex
+	templates map[string]*template.Template
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{templates: make(map[string]*template.Template)}
+}
+
+func (c *templateCache) get(key string) (*template.Template, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	tmpl, ok := c.templates[key]
+	return tmpl, ok
+}
+
+func (c *templateCache) set(key string, tmpl *template.Template) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.templates[key] = tmpl
+}
+
+func (c *templateCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.templates = make(map[string]*template.Template)
+}
+
+func parseTemplates(fsys fs.FS, funcs template.FuncMap, files []string) (*template.Template, error) {
+	name := path.Base(files[0])
+
+	tmpl, err := template.New(name).Funcs(funcs).ParseFS(fsys, files...)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse templates from %s: %v", strings.Join(files, ","), err)
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ListenAndServeTLS serves HTTPS, and HTTP/2 when the client supports it, on
+// addr. certFile and keyFile can be empty when ServerOptions.TLSConfig
+// provides the certificates.
+func (s *Server) ListenAndServeTLS(addr string, certFile string, keyFile string) error {
+	s.server.Addr = addr
+	return s.server.ListenAndServeTLS(certFile, keyFile)
+}
+
+// ServeTLS is the ListenAndServeTLS counterpart of Serve.
+func (s *Server) ServeTLS(l net.Listener, certFile string, keyFile string) error {
+	return s.server.ServeTLS(l, certFile, keyFile)
+}
+
+// ListenAndRedirectToHTTPS starts a plain HTTP server on addr redirecting every
+// request to the same URL on HTTPS. It is stopped by Shutdown, and returns
+// http.ErrServerClosed right away when Shutdown has already been called.
+func (s *Server) ListenAndRedirectToHTTPS(addr string, httpsPort string) error {
+	s.redirectMutex.Lock()
+	select {
+	case <-s.shuttingDown:
+		s.redirectMutex.Unlock()
+		return http.ErrServerClosed
+	default:
+	}
+
+	redirectServer := &http.Server{
+		Addr:              addr,
+		Handler:           HTTPSRedirectHandler(httpsPort),
+		ReadTimeout:       s.options.ReadTimeout,
+		ReadHeaderTimeout: s.options.ReadHeaderTimeout,
+		WriteTimeout:      s.options.WriteTimeout,
+		IdleTimeout:       s.options.IdleTimeout,
+		MaxHeaderBytes:    s.options.MaxHeaderBytes,
+	}
+	s.redirectServer = redirectServer
+	s.redirectMutex.Unlock()
+
+	return redirectServer.ListenAndServe()
+}
+
+// HTTPSRedirectHandler permanently redirects requests to HTTPS on httpsPort,
+// keeping the method, host, path and query. The port is omitted when it's 443.
+func HTTPSRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+type HSTSOptions struct {
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// HSTS sets the Strict-Transport-Security header on responses to requests
+// received over TLS. Browsers ignore it on plain HTTP.
+func HSTS(opts HSTSOptions) HTTPMiddleware {
+	value := fmt.Sprintf("max-age=%d", int64(opts.MaxAge.Seconds()))
+	if opts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if opts.Preload {
+		value += "; preload"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
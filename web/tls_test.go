@@ -0,0 +1,277 @@
+package web_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestServerServeOnListener(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		network := network
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "web.sock")
+			}
+
+			l, err := net.Listen(network, address)
+			testutils.RequireNoError(t, err, "can't listen")
+
+			server := setupServer(t)
+			server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				return ctx.JSONResponse(http.StatusOK, "hello")
+			})
+			startServer(t, server, func() error { return server.Serve(l) })
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, network, l.Addr().String())
+				},
+			}}
+
+			resp, err := client.Get("http://localhost/")
+			testutils.RequireNoError(t, err, "can't send request")
+			defer resp.Body.Close()
+
+			testutils.AssertEqualInt(t, http.StatusOK, resp.StatusCode, "unexpected http code")
+			testutils.AssertEqualString(t, `"hello"`+"\n", readBody(t, resp), "unexpected body")
+		})
+	}
+}
+
+func TestServerServeTLSWithConfig(t *testing.T) {
+	cert, pool := generateCertificate(t)
+
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates: testTmplConfiguration(),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+	})
+	server.UseHTTP(web.HSTS(web.HSTSOptions{MaxAge: 365 * 24 * time.Hour, IncludeSubDomains: true}))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "secure")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.RequireNoError(t, err, "can't listen")
+	startServer(t, server, func() error { return server.ServeTLS(l, "", "") })
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get("https://" + l.Addr().String() + "/")
+	testutils.RequireNoError(t, err, "can't send request")
+	defer resp.Body.Close()
+
+	testutils.AssertEqualInt(t, http.StatusOK, resp.StatusCode, "unexpected http code")
+	testutils.AssertEqualInt(t, 2, resp.ProtoMajor, "expected http/2")
+	testutils.AssertEqualString(t, "max-age=31536000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"), "unexpected hsts header")
+	testutils.AssertEqualString(t, `"secure"`+"\n", readBody(t, resp), "unexpected body")
+}
+
+func TestServerServeTLSWithFiles(t *testing.T) {
+	cert, pool := generateCertificate(t)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	testutils.RequireNoError(t, err, "can't marshal private key")
+	writePEM(t, certFile, "CERTIFICATE", cert.Certificate[0])
+	writePEM(t, keyFile, "PRIVATE KEY", key)
+
+	server := setupServer(t)
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "secure")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.RequireNoError(t, err, "can't listen")
+	startServer(t, server, func() error { return server.ServeTLS(l, certFile, keyFile) })
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}
+
+	resp, err := client.Get("https://" + l.Addr().String() + "/")
+	testutils.RequireNoError(t, err, "can't send request")
+	defer resp.Body.Close()
+
+	testutils.AssertEqualInt(t, http.StatusOK, resp.StatusCode, "unexpected http code")
+	testutils.AssertNotEqualNil(t, resp.TLS, "expected a tls connection")
+}
+
+func TestHSTSIsOnlySentOverTLS(t *testing.T) {
+	server := setupServer(t)
+	server.UseHTTP(web.HSTS(web.HSTSOptions{MaxAge: time.Hour, Preload: true}))
+	server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		return ctx.JSONResponse(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/", nil))
+	testutils.AssertEqualString(t, "", w.Header().Get("Strict-Transport-Security"), "unexpected hsts header over http")
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "https://example.com/", nil))
+	testutils.AssertEqualString(t, "max-age=3600; preload", w.Header().Get("Strict-Transport-Security"), "unexpected hsts header over https")
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tcs := map[string]struct {
+		port         string
+		target       string
+		wantLocation string
+	}{
+		"defaultPort": {port: "443", target: "http://example.com:8080/users?page=2", wantLocation: "https://example.com/users?page=2"},
+		"customPort":  {port: "8443", target: "http://example.com/users", wantLocation: "https://example.com:8443/users"},
+		"ipv6":        {port: "8443", target: "http://[::1]:8080/", wantLocation: "https://[::1]:8443/"},
+		"emptyPort":   {port: "", target: "http://example.com/", wantLocation: "https://example.com/"},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			web.HTTPSRedirectHandler(tc.port).ServeHTTP(w, httptest.NewRequest("POST", tc.target, nil))
+
+			testutils.AssertEqualInt(t, http.StatusPermanentRedirect, w.Code, "unexpected http code")
+			testutils.AssertEqualString(t, tc.wantLocation, w.Header().Get("Location"), "unexpected location")
+		})
+	}
+}
+
+func TestListenAndRedirectToHTTPSAfterShutdown(t *testing.T) {
+	server := setupServer(t)
+	testutils.RequireNoError(t, server.Shutdown(context.Background()), "can't shutdown server")
+
+	err := server.ListenAndRedirectToHTTPS("127.0.0.1:0", "443")
+
+	testutils.AssertErrorIs(t, http.ErrServerClosed, err, "expected redirection server to refuse to start")
+}
+
+func TestShutdownStopsMainServerWhenRedirectionServerFails(t *testing.T) {
+	server := setupServer(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.RequireNoError(t, err, "can't listen")
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(l) }()
+
+	redirectListener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.RequireNoError(t, err, "can't find a free port")
+	redirectAddr := redirectListener.Addr().String()
+	testutils.RequireNoError(t, redirectListener.Close(), "can't release free port")
+	redirected := make(chan error, 1)
+	go func() { redirected <- server.ListenAndRedirectToHTTPS(redirectAddr, "443") }()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", redirectAddr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutils.RequireNoError(t, err, "can't connect to redirection server")
+	defer conn.Close()
+
+	// an incomplete request keeps the connection active so the redirection
+	// server can't shutdown before the deadline
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\n"))
+	testutils.RequireNoError(t, err, "can't write partial request")
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	testutils.AssertErrorIs(t, context.DeadlineExceeded, err, "expected redirection server shutdown to fail")
+
+	select {
+	case err := <-served:
+		testutils.AssertErrorIs(t, http.ErrServerClosed, err, "expected main server to be closed")
+	case <-time.After(time.Second):
+		t.Fatal("expected main server to be shut down")
+	}
+	testutils.AssertErrorIs(t, http.ErrServerClosed, <-redirected, "expected redirection server to be closed")
+}
+
+func startServer(t *testing.T, server *web.Server, serve func() error) {
+	done := make(chan error, 1)
+	go func() { done <- serve() }()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		testutils.AssertNoError(t, server.Shutdown(ctx), "can't shutdown server")
+		if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("unexpected serve error: %v", err)
+		}
+	})
+}
+
+func generateCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutils.RequireNoError(t, err, "can't generate key")
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"golib test"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	testutils.RequireNoError(t, err, "can't create certificate")
+
+	parsed, err := x509.ParseCertificate(der)
+	testutils.RequireNoError(t, err, "can't parse certificate")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: parsed}, pool
+}
+
+func writePEM(t *testing.T, path string, kind string, content []byte) {
+	f, err := os.Create(path)
+	testutils.RequireNoError(t, err, "can't create pem file")
+	defer f.Close()
+
+	err = pem.Encode(f, &pem.Block{Type: kind, Bytes: content})
+	testutils.RequireNoError(t, err, "can't write pem file")
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	testutils.RequireNoError(t, err, "can't read body")
+
+	return string(body)
+}
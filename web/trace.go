@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const maxRequestIDLength = 128
+
+var (
+	traceparentRegexp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+	requestIDRegexp   = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+)
+
+// requestTraceID reuses the trace ID of an incoming W3C traceparent header, or
+// else the X-Request-ID header, so logs can be correlated with upstream
+// services. A new ID is generated when none is valid.
+func requestTraceID(r *http.Request) string {
+	if traceID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		return traceID
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+	if len(requestID) <= maxRequestIDLength && requestIDRegexp.MatchString(requestID) {
+		return requestID
+	}
+
+	return uuid.NewString()
+}
+
+func parseTraceparent(header string) (string, bool) {
+	matches := traceparentRegexp.FindStringSubmatch(strings.TrimSpace(header))
+	if matches == nil {
+		return "", false
+	}
+
+	version, traceID, parentID := matches[1], matches[2], matches[3]
+	if version == "ff" || traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return "", false
+	}
+
+	return traceID, true
+}
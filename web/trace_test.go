@@ -0,0 +1,127 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/lonepeon/golib/job"
+	"github.com/lonepeon/golib/job/jobtest"
+	"github.com/lonepeon/golib/logger/loggertest"
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+)
+
+func TestServerTraceID(t *testing.T) {
+	testCases := map[string]struct {
+		headers     map[string]string
+		wantTraceID string
+	}{
+		"traceparent": {
+			headers:     map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		"traceparentWinsOverRequestID": {
+			headers: map[string]string{
+				"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"X-Request-ID": "my-request-id",
+			},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		"invalidTraceparentFallsBackToRequestID": {
+			headers: map[string]string{
+				"traceparent":  "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+				"X-Request-ID": "my-request-id",
+			},
+			wantTraceID: "my-request-id",
+		},
+		"requestID": {
+			headers:     map[string]string{"X-Request-ID": "my-request-id"},
+			wantTraceID: "my-request-id",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			log, logs, closer := loggertest.NewFake(t)
+			server := web.NewServer(log, testTmplConfiguration(), sessions.NewCookieStore([]byte("secret-key")))
+
+			var traceID string
+			server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				traceID = ctx.TraceID()
+				ctx.Logger().Info("from handler")
+				return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+			closer()
+
+			testutils.AssertEqualString(t, tc.wantTraceID, traceID, "unexpected context trace id")
+			testutils.AssertEqualString(t, tc.wantTraceID, w.Header().Get("Trace-ID"), "unexpected trace id header")
+
+			lines := logs.Lines()
+			testutils.RequireEqualInt(t, 2, len(lines), "unexpected number of log lines")
+			testutils.AssertEqualString(t, "from handler", lines[0]["msg"].(string), "unexpected log message")
+			for _, line := range lines {
+				testutils.AssertEqualString(t, tc.wantTraceID, line["trace-id"].(string), "unexpected logged trace id")
+			}
+		})
+	}
+}
+
+func TestServerTraceIDIgnoresInvalidRequestID(t *testing.T) {
+	for name, requestID := range map[string]string{
+		"tooLong":      string(make([]byte, 129)),
+		"controlChars": "id\nfake-log-line",
+		"spaces":       "my request",
+	} {
+		requestID := requestID
+		t.Run(name, func(t *testing.T) {
+			server := setupServer(t)
+			server.HandleFunc("GET", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Request-ID", requestID)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+
+			traceID := w.Header().Get("Trace-ID")
+			testutils.AssertNotEmptyString(t, traceID, "expected a generated trace id")
+			testutils.AssertEqualBool(t, false, traceID == requestID, "expected request id to be ignored")
+		})
+	}
+}
+
+func TestServerTraceIDIsPropagatedToJobs(t *testing.T) {
+	jobs := jobtest.NewClient()
+	server := setupServer(t)
+	server.HandleFunc("POST", "/", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		j, err := job.NewJob("send-email", nil)
+		if err != nil {
+			return ctx.InternalServerErrorResponse("can't build job: %v", err)
+		}
+
+		if err := jobs.EnqueueContext(ctx.StdCtx(), j); err != nil {
+			return ctx.InternalServerErrorResponse("can't enqueue job: %v", err)
+		}
+
+		return ctx.Response(http.StatusOK, "testdata/templates/page.html", nil)
+	})
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Request-ID", "my-request-id")
+	serve(t, server, r, http.StatusOK)
+
+	enqueued := jobs.JobsNamed("send-email")
+	testutils.RequireEqualInt(t, 1, len(enqueued), "unexpected number of jobs")
+	testutils.AssertEqualString(t, "my-request-id", enqueued[0].TraceID, "unexpected job trace id")
+}
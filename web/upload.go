@@ -0,0 +1,201 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadMissing            = errors.New("no file uploaded")
+	ErrUploadTooLarge           = errors.New("uploaded file is too large")
+	ErrUploadInvalidContentType = errors.New("uploaded file content type is not allowed")
+	ErrUploadNotFound           = errors.New("uploaded file not found")
+)
+
+// sniffLength is the number of bytes http.DetectContentType looks at.
+const sniffLength = 512
+
+type UploadStorer interface {
+	// Store saves content under key. The content is streamed so it must be
+	// consumed only once.
+	Store(ctx context.Context, key string, contentType string, content io.Reader) error
+
+	// Open returns the content stored under key.
+	// It returns an ErrUploadNotFound if nothing is stored under this key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type UploadOptions struct {
+	// MaxSize rejects files bigger than this number of bytes, as soon as
+	// they go over it. The request body is limited by ServerOptions.MaxBodyBytes anyway.
+	MaxSize int64
+	// AllowedContentTypes restricts the sniffed content type of the file,
+	// ignoring its parameters such as charset. Any type is accepted when empty.
+	AllowedContentTypes []string
+}
+
+type Upload struct {
+	Key         string
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+// receiveUpload streams the file sent as field to storage under a random key
+// keeping the original extension. The content type is sniffed from the file
+// content rather than trusted from the client. The multipart body is read part
+// by part so the file is never buffered, unless the form has already been
+// parsed, for instance by BindForm or by the CSRF check of a form without the
+// CSRF header.
+func receiveUpload(r *http.Request, field string, storage UploadStorer, opts UploadOptions) (Upload, error) {
+	if r.MultipartForm != nil {
+		return receiveParsedUpload(r, field, storage, opts)
+	}
+
+	part, err := nextFilePart(r, field)
+	if err != nil {
+		return Upload{}, err
+	}
+	defer part.Close()
+
+	return storeUpload(r.Context(), part.FileName(), part, storage, opts)
+}
+
+// nextFilePart skips the multipart body until the file sent as field.
+func nextFilePart(r *http.Request, field string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, fmt.Errorf("can't read field %s: %w", field, ErrUploadMissing)
+		}
+		return nil, fmt.Errorf("can't read upload form: %v", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("can't read field %s: %w", field, ErrUploadMissing)
+		}
+		if err != nil {
+			if isBodyTooLarge(err) {
+				return nil, fmt.Errorf("can't read upload form: %w", ErrUploadTooLarge)
+			}
+			return nil, fmt.Errorf("can't read upload form: %v", err)
+		}
+
+		if part.FormName() == field && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+func receiveParsedUpload(r *http.Request, field string, storage UploadStorer, opts UploadOptions) (Upload, error) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return Upload{}, fmt.Errorf("can't read field %s: %w", field, ErrUploadMissing)
+		}
+		return Upload{}, fmt.Errorf("can't read field %s: %v", field, err)
+	}
+	defer file.Close()
+
+	if opts.MaxSize > 0 && header.Size > opts.MaxSize {
+		return Upload{}, fmt.Errorf("can't accept %d bytes file (max=%d): %w", header.Size, opts.MaxSize, ErrUploadTooLarge)
+	}
+
+	return storeUpload(r.Context(), header.Filename, file, storage, opts)
+}
+
+func storeUpload(ctx context.Context, filename string, file io.Reader, storage UploadStorer, opts UploadOptions) (Upload, error) {
+	content := &uploadReader{reader: file, maxSize: opts.MaxSize}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Upload{}, content.wrapError("can't read uploaded file", err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !isAllowedContentType(contentType, opts.AllowedContentTypes) {
+		return Upload{}, fmt.Errorf("can't accept %s file: %w", contentType, ErrUploadInvalidContentType)
+	}
+
+	filename = filepath.Base(filepath.Clean("/" + strings.ReplaceAll(filename, `\`, "/")))
+	upload := Upload{
+		Key:         uuid.NewString() + strings.ToLower(filepath.Ext(filename)),
+		Filename:    filename,
+		ContentType: contentType,
+	}
+
+	if err := storage.Store(ctx, upload.Key, upload.ContentType, io.MultiReader(bytes.NewReader(head), content)); err != nil {
+		return Upload{}, content.wrapError(fmt.Sprintf("can't store uploaded file %s", upload.Key), err)
+	}
+	upload.Size = content.read
+
+	return upload, nil
+}
+
+// uploadReader counts the bytes of the uploaded file and fails as soon as it
+// goes over maxSize, so the storage never receives more than that.
+type uploadReader struct {
+	reader  io.Reader
+	maxSize int64
+	read    int64
+	err     error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.reader.Read(p)
+	u.read += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		u.err = err
+	}
+
+	if u.maxSize > 0 && u.read > u.maxSize {
+		return n, fmt.Errorf("can't accept more than %d bytes: %w", u.maxSize, ErrUploadTooLarge)
+	}
+
+	return n, err
+}
+
+// wrapError reports a too large file or request body whatever the way the
+// storage wrapped the read error.
+func (u *uploadReader) wrapError(msg string, err error) error {
+	if (u.maxSize > 0 && u.read > u.maxSize) || isBodyTooLarge(u.err) {
+		return fmt.Errorf("%s: %w", msg, ErrUploadTooLarge)
+	}
+
+	return fmt.Errorf("%s: %v", msg, err)
+}
+
+func isAllowedContentType(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	for _, a := range allowed {
+		if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBodyTooLarge detects the error returned by http.MaxBytesReader. Its
+// message is compared as the typed error isn't available in all supported Go versions.
+func isBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
@@ -0,0 +1,209 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+	"github.com/lonepeon/golib/web/uploadstore"
+)
+
+var pngContent = append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte{0}, 100)...)
+
+func TestReceiveUpload(t *testing.T) {
+	storage := uploadstore.NewLocalFS(t.TempDir())
+	server := setupServer(t)
+
+	var upload web.Upload
+	server.Post("/avatar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		var err error
+		upload, err = ctx.ReceiveUpload(r, "avatar", storage, web.UploadOptions{
+			MaxSize:             1024,
+			AllowedContentTypes: []string{"image/png", "image/jpeg"},
+		})
+		if err != nil {
+			return ctx.InternalServerErrorResponse("can't receive upload: %v", err)
+		}
+		return ctx.JSONResponse(http.StatusCreated, "ok")
+	})
+
+	serve(t, server, newUploadRequest(t, "/avatar", "avatar", `..\..\My Avatar.PNG`, pngContent), http.StatusCreated)
+
+	testutils.AssertEqualString(t, "My Avatar.PNG", upload.Filename, "unexpected sanitized filename")
+	testutils.AssertEqualString(t, "image/png", upload.ContentType, "unexpected content type")
+	testutils.AssertEqualInt64(t, int64(len(pngContent)), upload.Size, "unexpected size")
+	testutils.AssertEqualBool(t, true, strings.HasSuffix(upload.Key, ".png"), "expected key to keep the extension: %s", upload.Key)
+
+	f, err := storage.Open(context.Background(), upload.Key)
+	testutils.RequireNoError(t, err, "can't open stored file")
+	defer f.Close()
+	stored, err := io.ReadAll(f)
+	testutils.RequireNoError(t, err, "can't read stored file")
+	testutils.AssertEqualBytes(t, pngContent, stored, "unexpected stored content")
+}
+
+func TestReceiveUploadErrors(t *testing.T) {
+	tcs := map[string]struct {
+		maxBodyBytes int64
+		field        string
+		content      []byte
+		wantErr      error
+	}{
+		"missingFile": {
+			field:   "other",
+			content: pngContent,
+			wantErr: web.ErrUploadMissing,
+		},
+		"fileTooLarge": {
+			field:   "avatar",
+			content: pngOfSize(2048),
+			wantErr: web.ErrUploadTooLarge,
+		},
+		"bodyTooLarge": {
+			maxBodyBytes: 256,
+			field:        "avatar",
+			content:      pngOfSize(512),
+			wantErr:      web.ErrUploadTooLarge,
+		},
+		"invalidContentType": {
+			field:   "avatar",
+			content: []byte("<html><script>alert(1)</script></html>"),
+			wantErr: web.ErrUploadInvalidContentType,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			server := newTestServerWithOptions(t, web.ServerOptions{
+				Templates:    testTmplConfiguration(),
+				MaxBodyBytes: tc.maxBodyBytes,
+			})
+
+			var err error
+			server.Post("/avatar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+				_, err = ctx.ReceiveUpload(r, "avatar", uploadstore.NewLocalFS(dir), web.UploadOptions{
+					MaxSize:             1024,
+					AllowedContentTypes: []string{"image/png"},
+				})
+				return ctx.JSONErrorResponse(http.StatusBadRequest, "invalid upload")
+			})
+
+			serve(t, server, newUploadRequest(t, "/avatar", tc.field, "avatar.png", tc.content), http.StatusBadRequest)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("unexpected error\nwant: %v\ngot:  %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReceiveUploadStopsReadingAboveMaxSize(t *testing.T) {
+	storage := &countingStorer{}
+	server := setupServer(t)
+
+	var err error
+	server.Post("/avatar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		_, err = ctx.ReceiveUpload(r, "avatar", storage, web.UploadOptions{MaxSize: 1024})
+		return ctx.JSONErrorResponse(http.StatusBadRequest, "invalid upload")
+	})
+
+	serve(t, server, newUploadRequest(t, "/avatar", "avatar", "avatar.png", pngOfSize(1<<20)), http.StatusBadRequest)
+
+	testutils.AssertErrorIs(t, web.ErrUploadTooLarge, err, "unexpected error")
+	testutils.AssertEqualBool(t, true, storage.read < 64<<10, "expected storage to stop reading early, read %d bytes", storage.read)
+}
+
+func TestReceiveUploadOfParsedForm(t *testing.T) {
+	storage := uploadstore.NewLocalFS(t.TempDir())
+	server := setupServer(t)
+
+	var upload web.Upload
+	server.Post("/avatar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		testutils.RequireNoError(t, r.ParseMultipartForm(1024), "can't parse form")
+
+		var err error
+		upload, err = ctx.ReceiveUpload(r, "avatar", storage, web.UploadOptions{MaxSize: 1024})
+		if err != nil {
+			return ctx.InternalServerErrorResponse("can't receive upload: %v", err)
+		}
+		return ctx.JSONResponse(http.StatusCreated, "ok")
+	})
+
+	serve(t, server, newUploadRequest(t, "/avatar", "avatar", "avatar.png", pngContent), http.StatusCreated)
+
+	testutils.AssertEqualString(t, "image/png", upload.ContentType, "unexpected content type")
+	testutils.AssertEqualInt64(t, int64(len(pngContent)), upload.Size, "unexpected size")
+}
+
+func TestReceiveUploadWithoutMaxBodyBytes(t *testing.T) {
+	storage := &countingStorer{}
+	server := newTestServerWithOptions(t, web.ServerOptions{
+		Templates:    testTmplConfiguration(),
+		MaxBodyBytes: web.NoMaxBodyBytes,
+	})
+
+	var upload web.Upload
+	server.Post("/avatar", func(ctx web.Context, w http.ResponseWriter, r *http.Request) web.Response {
+		var err error
+		upload, err = ctx.ReceiveUpload(r, "avatar", storage, web.UploadOptions{})
+		if err != nil {
+			return ctx.InternalServerErrorResponse("can't receive upload: %v", err)
+		}
+		return ctx.JSONResponse(http.StatusCreated, "ok")
+	})
+
+	content := pngOfSize(web.DefaultMaxBodyBytes + 1)
+	serve(t, server, newUploadRequest(t, "/avatar", "avatar", "avatar.png", content), http.StatusCreated)
+
+	testutils.AssertEqualInt64(t, int64(len(content)), upload.Size, "unexpected size")
+	testutils.AssertEqualInt64(t, int64(len(content)), storage.read, "unexpected stored size")
+}
+
+// countingStorer discards the uploaded content, only counting its bytes.
+type countingStorer struct {
+	read int64
+}
+
+func (s *countingStorer) Store(ctx context.Context, key string, contentType string, content io.Reader) error {
+	n, err := io.Copy(io.Discard, content)
+	s.read += n
+
+	return err
+}
+
+func (s *countingStorer) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, web.ErrUploadNotFound
+}
+
+func pngOfSize(size int) []byte {
+	content := make([]byte, size)
+	copy(content, pngContent)
+
+	return content
+}
+
+func newUploadRequest(t *testing.T, target string, field string, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile(field, filename)
+	testutils.RequireNoError(t, err, "can't create form file")
+	_, err = part.Write(content)
+	testutils.RequireNoError(t, err, "can't write form file")
+	testutils.RequireNoError(t, writer.Close(), "can't close multipart writer")
+
+	r := httptest.NewRequest("POST", target, &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return r
+}
@@ -0,0 +1,78 @@
+package uploadstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lonepeon/golib/web"
+)
+
+var _ web.UploadStorer = &LocalFS{}
+
+// LocalFS stores uploads as files in a directory of the local filesystem.
+type LocalFS struct {
+	dir string
+}
+
+func NewLocalFS(dir string) *LocalFS {
+	return &LocalFS{dir: dir}
+}
+
+// Store writes content to a temporary file first so a failed or interrupted
+// upload never leaves a partial file under key.
+func (l *LocalFS) Store(ctx context.Context, key string, contentType string, content io.Reader) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("can't create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("can't write temporary file: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("can't close temporary file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("can't move temporary file to %s: %v", path, err)
+	}
+
+	return nil
+}
+
+func (l *LocalFS) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("can't open %s: %w", key, web.ErrUploadNotFound)
+		}
+		return nil, fmt.Errorf("can't open %s: %v", key, err)
+	}
+
+	return f, nil
+}
+
+func (l *LocalFS) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid upload key %q", key)
+	}
+
+	return filepath.Join(l.dir, key), nil
+}
@@ -0,0 +1,44 @@
+package uploadstore_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lonepeon/golib/testutils"
+	"github.com/lonepeon/golib/web"
+	"github.com/lonepeon/golib/web/uploadstore"
+)
+
+func TestLocalFSStoreAndOpen(t *testing.T) {
+	store := uploadstore.NewLocalFS(t.TempDir())
+
+	err := store.Store(context.Background(), "file.txt", "text/plain", strings.NewReader("hello"))
+	testutils.RequireNoError(t, err, "can't store file")
+
+	f, err := store.Open(context.Background(), "file.txt")
+	testutils.RequireNoError(t, err, "can't open file")
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	testutils.RequireNoError(t, err, "can't read file")
+	testutils.AssertEqualString(t, "hello", string(content), "unexpected content")
+}
+
+func TestLocalFSOpenNotFound(t *testing.T) {
+	store := uploadstore.NewLocalFS(t.TempDir())
+
+	_, err := store.Open(context.Background(), "unknown.txt")
+
+	testutils.AssertErrorIs(t, web.ErrUploadNotFound, err, "expected missing file")
+}
+
+func TestLocalFSInvalidKey(t *testing.T) {
+	store := uploadstore.NewLocalFS(t.TempDir())
+
+	for _, key := range []string{"", "..", "../file.txt", "dir/file.txt"} {
+		err := store.Store(context.Background(), key, "text/plain", strings.NewReader("hello"))
+		testutils.AssertErrorContains(t, "invalid upload key", err, "expected key %q to be rejected", key)
+	}
+}
@@ -19,6 +19,7 @@ func AssertResponse(t *testing.T, want web.Response, got web.Response, format st
 	testutils.AssertEqualString(t, want.Layout, got.Layout, explanation("unexpected response layout"))
 	testutils.AssertEqualString(t, want.Template, got.Template, explanation("unexpected response template"))
 	testutils.AssertEqualInt(t, want.HTTPCode, got.HTTPCode, explanation("unexpected response http code"))
+	testutils.AssertEqualInt(t, int(want.Format), int(got.Format), explanation("unexpected response format"))
 
 	if !reflect.DeepEqual(want.Data, got.Data) {
 		t.Errorf("%s\nwant:/n%#+v\ngot:\n%#+v\n", explanation("unexpected response data"), want.Data, got.Data)
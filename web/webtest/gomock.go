@@ -9,11 +9,19 @@ import (
 )
 
 func MatchFlashErrorContains(v string) _GoMockFlashMessageContains {
-	return _GoMockFlashMessageContains{kind: "error", value: v}
+	return _GoMockFlashMessageContains{kind: web.FlashKindError, value: v}
+}
+
+func MatchFlashWarningContains(v string) _GoMockFlashMessageContains {
+	return _GoMockFlashMessageContains{kind: web.FlashKindWarning, value: v}
+}
+
+func MatchFlashInfoContains(v string) _GoMockFlashMessageContains {
+	return _GoMockFlashMessageContains{kind: web.FlashKindInfo, value: v}
 }
 
 func MatchFlashSuccessContains(v string) _GoMockFlashMessageContains {
-	return _GoMockFlashMessageContains{kind: "success", value: v}
+	return _GoMockFlashMessageContains{kind: web.FlashKindSuccess, value: v}
 }
 
 func MatchDataContains(k string, v interface{}) _GoMockDataContains {
@@ -21,7 +29,7 @@ func MatchDataContains(k string, v interface{}) _GoMockDataContains {
 }
 
 type _GoMockFlashMessageContains struct {
-	kind  string
+	kind  web.FlashKind
 	value string
 }
 
@@ -31,11 +39,11 @@ func (m _GoMockFlashMessageContains) Matches(v interface{}) bool {
 		return false
 	}
 
-	return flash.Kind == m.kind && strings.Contains(flash.Message, m.value)
+	return flash.Kind == m.kind && (strings.Contains(flash.Message, m.value) || flash.TranslationKey == m.value)
 }
 
 func (m _GoMockFlashMessageContains) String() string {
-	return fmt.Sprintf("flash %s should contain %s", m.kind, m.value)
+	return fmt.Sprintf("flash %s should contain %s or have it as translation key", m.kind, m.value)
 }
 
 type _GoMockDataContains struct {
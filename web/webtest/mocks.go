@@ -1,3 +1,3 @@
 package webtest
 
-//go:generate mockgen -destination=web.go -package webtest github.com/lonepeon/golib/web AuthenticationFrontendStorer,AuthenticationBackendStorer,Handler,Context
+//go:generate mockgen -destination=web.go -package webtest github.com/lonepeon/golib/web AuthenticationFrontendStorer,AuthenticationBackendStorer,Handler,Context,UploadStorer
@@ -1,15 +1,17 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/lonepeon/golib/web (interfaces: AuthenticationFrontendStorer,AuthenticationBackendStorer,Handler,Context)
+// Source: github.com/lonepeon/golib/web (interfaces: AuthenticationFrontendStorer,AuthenticationBackendStorer,Handler,Context,UploadStorer)
 
 // Package webtest is a generated GoMock package.
 package webtest
 
 import (
 	context "context"
+	io "io"
 	http "net/http"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	logger "github.com/lonepeon/golib/logger"
 	web "github.com/lonepeon/golib/web"
 )
 
@@ -231,6 +233,48 @@ func (mr *MockContextMockRecorder) AddFlash(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFlash", reflect.TypeOf((*MockContext)(nil).AddFlash), arg0)
 }
 
+// BindForm mocks base method.
+func (m *MockContext) BindForm(arg0 *http.Request, arg1 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindForm", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindForm indicates an expected call of BindForm.
+func (mr *MockContextMockRecorder) BindForm(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindForm", reflect.TypeOf((*MockContext)(nil).BindForm), arg0, arg1)
+}
+
+// ConsumeFlashes mocks base method.
+func (m *MockContext) ConsumeFlashes(arg0 string) []web.FlashMessage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeFlashes", arg0)
+	ret0, _ := ret[0].([]web.FlashMessage)
+	return ret0
+}
+
+// ConsumeFlashes indicates an expected call of ConsumeFlashes.
+func (mr *MockContextMockRecorder) ConsumeFlashes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeFlashes", reflect.TypeOf((*MockContext)(nil).ConsumeFlashes), arg0)
+}
+
+// EventStreamResponse mocks base method.
+func (m *MockContext) EventStreamResponse(arg0 web.EventStreamFunc) web.Response {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EventStreamResponse", arg0)
+	ret0, _ := ret[0].(web.Response)
+	return ret0
+}
+
+// EventStreamResponse indicates an expected call of EventStreamResponse.
+func (mr *MockContextMockRecorder) EventStreamResponse(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventStreamResponse", reflect.TypeOf((*MockContext)(nil).EventStreamResponse), arg0)
+}
+
 // InternalServerErrorResponse mocks base method.
 func (m *MockContext) InternalServerErrorResponse(arg0 string, arg1 ...interface{}) web.Response {
 	m.ctrl.T.Helper()
@@ -250,6 +294,81 @@ func (mr *MockContextMockRecorder) InternalServerErrorResponse(arg0 interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InternalServerErrorResponse", reflect.TypeOf((*MockContext)(nil).InternalServerErrorResponse), varargs...)
 }
 
+// JSONErrorResponse mocks base method.
+func (m *MockContext) JSONErrorResponse(arg0 int, arg1 string, arg2 ...interface{}) web.Response {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "JSONErrorResponse", varargs...)
+	ret0, _ := ret[0].(web.Response)
+	return ret0
+}
+
+// JSONErrorResponse indicates an expected call of JSONErrorResponse.
+func (mr *MockContextMockRecorder) JSONErrorResponse(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JSONErrorResponse", reflect.TypeOf((*MockContext)(nil).JSONErrorResponse), varargs...)
+}
+
+// JSONResponse mocks base method.
+func (m *MockContext) JSONResponse(arg0 int, arg1 interface{}) web.Response {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JSONResponse", arg0, arg1)
+	ret0, _ := ret[0].(web.Response)
+	return ret0
+}
+
+// JSONResponse indicates an expected call of JSONResponse.
+func (mr *MockContextMockRecorder) JSONResponse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JSONResponse", reflect.TypeOf((*MockContext)(nil).JSONResponse), arg0, arg1)
+}
+
+// Locale mocks base method.
+func (m *MockContext) Locale() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Locale")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Locale indicates an expected call of Locale.
+func (mr *MockContextMockRecorder) Locale() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Locale", reflect.TypeOf((*MockContext)(nil).Locale))
+}
+
+// Logger mocks base method.
+func (m *MockContext) Logger() *logger.Logger {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logger")
+	ret0, _ := ret[0].(*logger.Logger)
+	return ret0
+}
+
+// Logger indicates an expected call of Logger.
+func (mr *MockContextMockRecorder) Logger() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logger", reflect.TypeOf((*MockContext)(nil).Logger))
+}
+
+// NegotiatedResponse mocks base method.
+func (m *MockContext) NegotiatedResponse(arg0 *http.Request, arg1 int, arg2 string, arg3 map[string]interface{}) web.Response {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NegotiatedResponse", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(web.Response)
+	return ret0
+}
+
+// NegotiatedResponse indicates an expected call of NegotiatedResponse.
+func (mr *MockContextMockRecorder) NegotiatedResponse(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NegotiatedResponse", reflect.TypeOf((*MockContext)(nil).NegotiatedResponse), arg0, arg1, arg2, arg3)
+}
+
 // NotFoundResponse mocks base method.
 func (m *MockContext) NotFoundResponse(arg0 string, arg1 ...interface{}) web.Response {
 	m.ctrl.T.Helper()
@@ -269,6 +388,35 @@ func (mr *MockContextMockRecorder) NotFoundResponse(arg0 interface{}, arg1 ...in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotFoundResponse", reflect.TypeOf((*MockContext)(nil).NotFoundResponse), varargs...)
 }
 
+// PeekFlashes mocks base method.
+func (m *MockContext) PeekFlashes(arg0 string) []web.FlashMessage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PeekFlashes", arg0)
+	ret0, _ := ret[0].([]web.FlashMessage)
+	return ret0
+}
+
+// PeekFlashes indicates an expected call of PeekFlashes.
+func (mr *MockContextMockRecorder) PeekFlashes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeekFlashes", reflect.TypeOf((*MockContext)(nil).PeekFlashes), arg0)
+}
+
+// ReceiveUpload mocks base method.
+func (m *MockContext) ReceiveUpload(arg0 *http.Request, arg1 string, arg2 web.UploadStorer, arg3 web.UploadOptions) (web.Upload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveUpload", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(web.Upload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReceiveUpload indicates an expected call of ReceiveUpload.
+func (mr *MockContextMockRecorder) ReceiveUpload(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveUpload", reflect.TypeOf((*MockContext)(nil).ReceiveUpload), arg0, arg1, arg2, arg3)
+}
+
 // Redirect mocks base method.
 func (m *MockContext) Redirect(arg0 http.ResponseWriter, arg1 int, arg2 string) web.Response {
 	m.ctrl.T.Helper()
@@ -297,6 +445,18 @@ func (mr *MockContextMockRecorder) Response(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Response", reflect.TypeOf((*MockContext)(nil).Response), arg0, arg1, arg2)
 }
 
+// SetLocale mocks base method.
+func (m *MockContext) SetLocale(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetLocale", arg0)
+}
+
+// SetLocale indicates an expected call of SetLocale.
+func (mr *MockContextMockRecorder) SetLocale(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLocale", reflect.TypeOf((*MockContext)(nil).SetLocale), arg0)
+}
+
 // StdCtx mocks base method.
 func (m *MockContext) StdCtx() context.Context {
 	m.ctrl.T.Helper()
@@ -311,6 +471,39 @@ func (mr *MockContextMockRecorder) StdCtx() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StdCtx", reflect.TypeOf((*MockContext)(nil).StdCtx))
 }
 
+// T mocks base method.
+func (m *MockContext) T(arg0 string, arg1 ...interface{}) string {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "T", varargs...)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// T indicates an expected call of T.
+func (mr *MockContextMockRecorder) T(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "T", reflect.TypeOf((*MockContext)(nil).T), varargs...)
+}
+
+// TraceID mocks base method.
+func (m *MockContext) TraceID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TraceID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// TraceID indicates an expected call of TraceID.
+func (mr *MockContextMockRecorder) TraceID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TraceID", reflect.TypeOf((*MockContext)(nil).TraceID))
+}
+
 // Vars mocks base method.
 func (m *MockContext) Vars(arg0 *http.Request) map[string]string {
 	m.ctrl.T.Helper()
@@ -324,3 +517,55 @@ func (mr *MockContextMockRecorder) Vars(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vars", reflect.TypeOf((*MockContext)(nil).Vars), arg0)
 }
+
+// MockUploadStorer is a mock of UploadStorer interface.
+type MockUploadStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploadStorerMockRecorder
+}
+
+// MockUploadStorerMockRecorder is the mock recorder for MockUploadStorer.
+type MockUploadStorerMockRecorder struct {
+	mock *MockUploadStorer
+}
+
+// NewMockUploadStorer creates a new mock instance.
+func NewMockUploadStorer(ctrl *gomock.Controller) *MockUploadStorer {
+	mock := &MockUploadStorer{ctrl: ctrl}
+	mock.recorder = &MockUploadStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUploadStorer) EXPECT() *MockUploadStorerMockRecorder {
+	return m.recorder
+}
+
+// Open mocks base method.
+func (m *MockUploadStorer) Open(arg0 context.Context, arg1 string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Open", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Open indicates an expected call of Open.
+func (mr *MockUploadStorerMockRecorder) Open(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockUploadStorer)(nil).Open), arg0, arg1)
+}
+
+// Store mocks base method.
+func (m *MockUploadStorer) Store(arg0 context.Context, arg1, arg2 string, arg3 io.Reader) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Store", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Store indicates an expected call of Store.
+func (mr *MockUploadStorerMockRecorder) Store(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockUploadStorer)(nil).Store), arg0, arg1, arg2, arg3)
+}